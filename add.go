@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runAdd(args []string) error {
+	fs := newFlagSet("add", "<archive> <files...>")
+	jobs := fs.Int("j", 0, "chunks to deflate at once (defaults to every cpu)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("an archive and at least one file are required")
+	}
+	path := fs.Arg(0)
+	return updateArchive(path, *jobs, func(u *pfs.Update) error {
+		for _, src := range fs.Args()[1:] {
+			data, err := ioutil.ReadFile(src)
+			if err != nil {
+				return err
+			}
+			err = u.Put(filepath.Base(src), data)
+			if err != nil {
+				return fmt.Errorf("put %s: %w", src, err)
+			}
+			fmt.Printf("%s: added %s\n", path, filepath.Base(src))
+		}
+		return nil
+	})
+}
+
+func runRm(args []string) error {
+	fs := newFlagSet("rm", "<archive> <names...>")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("an archive and at least one entry name are required")
+	}
+	path := fs.Arg(0)
+	return updateArchive(path, 0, func(u *pfs.Update) error {
+		for _, name := range fs.Args()[1:] {
+			err := u.Remove(name)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: removed %s\n", path, name)
+		}
+		return nil
+	})
+}
+
+// updateArchive applies the changes made by fn to the archive at path in place
+func updateArchive(path string, jobs int, fn func(u *pfs.Update) error) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	u, err := pfs.NewUpdate(f)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+	u.Concurrency = jobs
+	err = fn(u)
+	if err != nil {
+		return err
+	}
+	err = u.Apply()
+	if err != nil {
+		return fmt.Errorf("apply %s: %w", path, err)
+	}
+	return nil
+}
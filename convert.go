@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/eqzxc/eqemu"
+	"github.com/xackery/eqzxc/gltf"
+	"github.com/xackery/eqzxc/pfs"
+	"github.com/xackery/eqzxc/wld"
+)
+
+// errNoZone is returned when an archive has no zone wld, such as a _chr or _obj archive
+var errNoZone = errors.New("not a zone archive")
+
+// zone is the wld data of a zone archive and its _obj archive
+type zone struct {
+	name string
+	wld  *wld.Wld
+	// objects places models, and is nil if the zone has no objects.wld
+	objects *wld.Wld
+	// models are the actors objects places, and is nil if there is no _obj archive
+	models *wld.Wld
+}
+
+func runConvert(args []string) error {
+	fs := newFlagSet("convert", "[zone archives...]")
+	to := fs.String("to", "map,wtr,zonelines", "comma separated formats to write: map (EQEmu collision), wtr (EQEmu water), zonelines (json), gltf (meshes, and skinned characters with their animations), glb (gltf with textures in one file)")
+	out := fs.String("out", "", "directory to write to (defaults to the directory of each archive)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	targets := strings.Split(*to, ",")
+	for _, target := range targets {
+		switch target {
+		case "map", "wtr", "zonelines", "gltf", "glb":
+		default:
+			return fmt.Errorf("unknown format %s", target)
+		}
+	}
+	paths, err := archivePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err = convert(path, *out, targets)
+		if errors.Is(err, errNoZone) && len(paths) > 1 {
+			fmt.Printf("%s: skipped, %v\n", path, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("convert %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func convert(path string, out string, targets []string) error {
+	z, err := loadZone(path)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = filepath.Dir(path)
+	}
+	for _, target := range targets {
+		buf := &bytes.Buffer{}
+		dst := filepath.Join(out, z.name+"."+target)
+		switch target {
+		case "map":
+			m, err := eqemu.BuildMap(z.wld, z.objects, z.models)
+			if err != nil {
+				return fmt.Errorf("build map: %w", err)
+			}
+			err = m.Encode(buf)
+			if err != nil {
+				return fmt.Errorf("encode map: %w", err)
+			}
+		case "wtr":
+			wm, err := eqemu.BuildWaterMap(z.wld)
+			if err != nil {
+				return fmt.Errorf("build water map: %w", err)
+			}
+			err = wm.Encode(buf)
+			if err != nil {
+				return fmt.Errorf("encode water map: %w", err)
+			}
+		case "zonelines":
+			zoneLines, err := eqemu.ZoneLines(z.wld)
+			if err != nil {
+				return fmt.Errorf("zone lines: %w", err)
+			}
+			enc := json.NewEncoder(buf)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(zoneLines)
+			if err != nil {
+				return fmt.Errorf("encode zone lines: %w", err)
+			}
+			dst = filepath.Join(out, z.name+"_zonelines.json")
+		case "gltf", "glb":
+			g, err := gltf.FromWld(z.wld)
+			if err != nil {
+				return fmt.Errorf("build gltf: %w", err)
+			}
+			opts := gltf.SaveOptions{}
+			if target == "glb" {
+				opts.Binary = true
+				opts.Images, err = archiveFS(path)
+				if err != nil {
+					return fmt.Errorf("read textures: %w", err)
+				}
+			}
+			// SaveWithOptions takes a WriteSeeker, so gltf is written straight to the file
+			err = saveGltf(dst, g, opts)
+			if err != nil {
+				return fmt.Errorf("save %s: %w", target, err)
+			}
+			fmt.Printf("%s: wrote %s\n", path, dst)
+			continue
+		}
+		err = ioutil.WriteFile(dst, buf.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: wrote %s\n", path, dst)
+	}
+	return nil
+}
+
+// saveGltf writes g to a new file at path
+func saveGltf(path string, g *gltf.GLTF, opts gltf.SaveOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = gltf.SaveWithOptions(f, g, opts)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadZone decodes <name>.wld and objects.wld of a zone archive, and <name>_obj.wld of the _obj archive beside it
+func loadZone(path string) (*zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	z := &zone{name: name}
+	err := readArchive(path, func(pr *pfs.Reader) error {
+		var err error
+		z.wld, err = archiveWld(pr, name+".wld")
+		if err != nil {
+			return err
+		}
+		if z.wld == nil {
+			return fmt.Errorf("%w, no %s.wld", errNoZone, name)
+		}
+		z.objects, err = archiveWld(pr, "objects.wld")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	objPath := filepath.Join(filepath.Dir(path), name+"_obj"+filepath.Ext(path))
+	if _, err := os.Stat(objPath); err != nil {
+		return z, nil
+	}
+	err = readArchive(objPath, func(pr *pfs.Reader) error {
+		var err error
+		z.models, err = archiveWld(pr, name+"_obj.wld")
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", objPath, err)
+	}
+	return z, nil
+}
+
+// readArchive reads the directory of the archive at path, for fn to inflate only the entries it needs
+func readArchive(path string, fn func(pr *pfs.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pr, err := pfs.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+	return fn(pr)
+}
+
+// archiveFS decodes every entry of the archive at path
+func archiveFS(path string) (*pfs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	archive, err := pfs.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewFS(archive), nil
+}
+
+// archiveWld decodes the wld named name, or returns nil if the archive has none
+func archiveWld(pr *pfs.Reader, name string) (*wld.Wld, error) {
+	if _, ok := pr.Entry(name); !ok {
+		return nil, nil
+	}
+	data, err := pr.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	w, err := wld.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return w, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "<old archive> <new archive>")
+	asJSON := fs.Bool("json", false, "print the differences as json")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("two archives are required")
+	}
+	a, err := openArchive(fs.Arg(0), 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	b, err := openArchive(fs.Arg(1), 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+	d := pfs.Diff(a, b)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	if d.Empty() {
+		fmt.Printf("%s and %s have the same entries\n", fs.Arg(0), fs.Arg(1))
+		return nil
+	}
+	for _, entry := range d.Added {
+		fmt.Printf("added    %s %d bytes %s\n", entry.Name, entry.NewSize, entry.NewHash)
+	}
+	for _, entry := range d.Removed {
+		fmt.Printf("removed  %s %d bytes %s\n", entry.Name, entry.OldSize, entry.OldHash)
+	}
+	for _, entry := range d.Modified {
+		fmt.Printf("modified %s %d -> %d bytes (%+d) %s -> %s\n", entry.Name, entry.OldSize, entry.NewSize, entry.SizeDelta, entry.OldHash, entry.NewHash)
+		if entry.Fragments == nil {
+			continue
+		}
+		if entry.Fragments.Error != "" {
+			fmt.Printf("  fragments not compared: %s\n", entry.Fragments.Error)
+		}
+		for _, frag := range entry.Fragments.Added {
+			fmt.Printf("  added fragment %s\n", frag)
+		}
+		for _, frag := range entry.Fragments.Removed {
+			fmt.Printf("  removed fragment %s\n", frag)
+		}
+		for _, frag := range entry.Fragments.Modified {
+			fmt.Printf("  modified fragment %s\n", frag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package eqemu builds the collision, water and zone line data EQEmu zone servers load
+package eqemu
+
+import (
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// serverPoint converts a wld position to server coordinates, which swap x and y
+func serverPoint(v math32.Vector3) math32.Vector3 {
+	return math32.Vector3{X: v.Y, Y: v.X, Z: v.Z}
+}
+
+// ObjectTransform returns the matrix that places an object's meshes in the world
+func ObjectTransform(obj *fragment.ObjectInstance) *math32.Matrix4 {
+	rotation := math32.Vector3{
+		X: math32.DegToRad(obj.Rotation.X),
+		Y: math32.DegToRad(obj.Rotation.Y),
+		Z: math32.DegToRad(obj.Rotation.Z),
+	}
+	q := &math32.Quaternion{}
+	q.SetFromEuler(&rotation)
+	position := obj.Position
+	scale := obj.Scale
+	return math32.NewMatrix4().Compose(&position, q, &scale)
+}
@@ -0,0 +1,142 @@
+package eqemu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+func TestMap(t *testing.T) {
+	mesh := &fragment.Mesh{
+		Center:    math32.Vector3{X: 10},
+		Verticies: []math32.Vector3{{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 0, Y: 2, Z: 0}},
+		Indices: []*fragment.Polygon{
+			{IsSolid: true, Vertex1: 0, Vertex2: 1, Vertex3: 2},
+			{IsSolid: false, Vertex1: 2, Vertex2: 1, Vertex3: 0},
+		},
+	}
+	m := &Map{}
+	err := m.AddMesh(mesh, nil)
+	if err != nil {
+		t.Fatalf("add mesh: %v", err)
+	}
+	obj := &fragment.ObjectInstance{Position: math32.Vector3{Z: 5}, Scale: math32.Vector3{X: 2, Y: 2, Z: 2}}
+	err = m.AddMesh(mesh, ObjectTransform(obj))
+	if err != nil {
+		t.Fatalf("add object mesh: %v", err)
+	}
+	if len(m.Vertices) != 6 || len(m.Indices) != 6 || len(m.NonCollideIndices) != 6 {
+		t.Fatalf("vertices %d indices %d non collide indices %d", len(m.Vertices), len(m.Indices), len(m.NonCollideIndices))
+	}
+	// server coordinates swap x and y
+	if m.Vertices[2] != (math32.Vector3{X: 2, Y: 10}) {
+		t.Fatalf("vertex 2 wanted {2 10 0}, got %v", m.Vertices[2])
+	}
+	if m.Vertices[5] != (math32.Vector3{X: 4, Y: 20, Z: 5}) {
+		t.Fatalf("object vertex wanted {4 20 5}, got %v", m.Vertices[5])
+	}
+
+	mesh.Indices = append(mesh.Indices, &fragment.Polygon{Vertex1: 3})
+	err = m.AddMesh(mesh, nil)
+	if err == nil {
+		t.Fatalf("out of range polygon was added")
+	}
+
+	buf := &bytes.Buffer{}
+	m = &Map{Vertices: m.Vertices[:6], Indices: m.Indices[:6], NonCollideVertices: m.NonCollideVertices[:6], NonCollideIndices: m.NonCollideIndices[:6]}
+	err = m.Encode(buf)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if binary.LittleEndian.Uint32(buf.Bytes()) != 0x02000000 {
+		t.Fatalf("wanted version 2 header")
+	}
+	m2, err := DecodeMap(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(m2.Vertices) != 6 || m2.Vertices[5] != m.Vertices[5] || m2.NonCollideIndices[5] != m.NonCollideIndices[5] {
+		t.Fatalf("round trip changed the map")
+	}
+}
+
+func TestWaterMap(t *testing.T) {
+	w := &wld.Wld{IsOldWorld: true}
+	// tree split on wld y, region 1 in front and region 2 behind
+	w.Add(&fragment.BspTree{Nodes: []*fragment.BspNode{
+		{Normal: math32.Vector3{Y: 1}, Front: 2, Back: 3},
+		{RegionID: 1},
+		{RegionID: 2},
+	}})
+	for _, y := range []float32{5, -5} {
+		w.Add(&fragment.BspRegion{Flags: 0x81, Sphere: fragment.BspSphere{Center: math32.Vector3{Y: y}, Radius: 3}})
+	}
+	water := &fragment.RegionFlag{RegionIndices: []int{0}}
+	water.SetName("WT_ZONE")
+	w.Add(water)
+	zoneLine := &fragment.RegionFlag{RegionIndices: []int{1}}
+	zoneLine.SetName("DRNTP00002000100000200000300128_ZONE")
+	w.Add(zoneLine)
+
+	buf := &bufferWriteSeeker{}
+	err := w.Encode(buf)
+	if err != nil {
+		t.Fatalf("encode wld: %v", err)
+	}
+	zone, err := wld.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decode wld: %v", err)
+	}
+
+	wm, err := BuildWaterMap(zone)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	buf.Reset()
+	err = wm.Encode(buf)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("EQEMUWATER")) || buf.Len() != 10+8+3*36 {
+		t.Fatalf("encoded %d bytes", buf.Len())
+	}
+	wm, err = DecodeWaterMap(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// the split plane is stored as in the wld, the lookup swaps server x and y back to wld y and x
+	if wm.Nodes[0].Normal != (math32.Vector3{Y: 1}) {
+		t.Fatalf("root normal %v wanted the wld normal", wm.Nodes[0].Normal)
+	}
+	if got := wm.RegionTypeAt(math32.Vector3{X: 5}); got != fragment.RegionTypeWater {
+		t.Fatalf("server x 5 wanted water, got %s", got)
+	}
+	if got := wm.RegionTypeAt(math32.Vector3{X: -5, Y: 100}); got != fragment.RegionTypeZoneLine {
+		t.Fatalf("server x -5 wanted zone line, got %s", got)
+	}
+
+	zoneLines, err := ZoneLines(zone)
+	if err != nil {
+		t.Fatalf("zone lines: %v", err)
+	}
+	if len(zoneLines) != 1 {
+		t.Fatalf("wanted 1 zone line, got %d", len(zoneLines))
+	}
+	zl := zoneLines[0]
+	if zl.Region != 1 || zl.Center.X != -5 || zl.Radius != 3 || zl.ZoneID != 2 || zl.Position.Y != 200 || zl.Heading != 128 {
+		t.Fatalf("zone line %+v", zl)
+	}
+}
+
+// bufferWriteSeeker lets tests encode to memory, encoding only ever appends
+type bufferWriteSeeker struct {
+	bytes.Buffer
+}
+
+func (b *bufferWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	return int64(b.Len()), nil
+}
@@ -0,0 +1,180 @@
+package eqemu
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// mapVersion is the header of a version 2 map, which zlib compresses its geometry
+const mapVersion = 0x02000000
+
+// Map is an EQEmu .map collision file, a triangle soup of a zone and the objects placed in it
+type Map struct {
+	Vertices []math32.Vector3
+	Indices  []uint32
+	// NonCollideVertices and NonCollideIndices are polygons players walk through, such as foliage
+	NonCollideVertices []math32.Vector3
+	NonCollideIndices  []uint32
+}
+
+// BuildMap collects the meshes of a zone and, if objects is set, every object it places.
+// Placements in objects name actors found in models, usually the zone's _obj.wld
+func BuildMap(zone *wld.Wld, objects *wld.Wld, models *wld.Wld) (*Map, error) {
+	m := &Map{}
+	for _, frag := range zone.Fragments {
+		mesh, ok := frag.(*fragment.Mesh)
+		if !ok {
+			continue
+		}
+		err := m.AddMesh(mesh, nil)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %s: %w", mesh.Name(), err)
+		}
+	}
+	if objects == nil || models == nil {
+		return m, nil
+	}
+	for _, frag := range objects.Fragments {
+		obj, ok := frag.(*fragment.ObjectInstance)
+		if !ok {
+			continue
+		}
+		actor := models.Actor(obj)
+		if actor == nil {
+			continue
+		}
+		transform := ObjectTransform(obj)
+		for _, mesh := range models.ActorMeshes(actor) {
+			err := m.AddMesh(mesh, transform)
+			if err != nil {
+				return nil, fmt.Errorf("object %s mesh %s: %w", obj.ActorName, mesh.Name(), err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// AddMesh adds the polygons of mesh, moved into the world by transform if it is set
+func (m *Map) AddMesh(mesh *fragment.Mesh, transform *math32.Matrix4) error {
+	// mesh vertex index to map vertex index, one for each of solid and non solid polygons
+	solid := make(map[int]uint32)
+	nonSolid := make(map[int]uint32)
+	for i, p := range mesh.Indices {
+		for _, index := range []int{p.Vertex1, p.Vertex2, p.Vertex3} {
+			if index < 0 || index >= len(mesh.Verticies) {
+				return fmt.Errorf("polygon %d vertex %d out of range", i, index)
+			}
+			seen, vertices, indices := solid, &m.Vertices, &m.Indices
+			if !p.IsSolid {
+				seen, vertices, indices = nonSolid, &m.NonCollideVertices, &m.NonCollideIndices
+			}
+			mapIndex, ok := seen[index]
+			if !ok {
+				pos := mesh.Verticies[index]
+				pos.Add(&mesh.Center)
+				if transform != nil {
+					pos.ApplyMatrix4(transform)
+				}
+				mapIndex = uint32(len(*vertices))
+				seen[index] = mapIndex
+				*vertices = append(*vertices, serverPoint(pos))
+			}
+			*indices = append(*indices, mapIndex)
+		}
+	}
+	return nil
+}
+
+// Encode writes a version 2 map
+func (m *Map) Encode(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	// vertex, index, non collide vertex and index counts, then model, placeable,
+	// placeable group and terrain tile counts, quads per tile and units per vertex, all unused
+	err := binary.Write(buf, binary.LittleEndian, []uint32{uint32(len(m.Vertices)), uint32(len(m.Indices)), uint32(len(m.NonCollideVertices)), uint32(len(m.NonCollideIndices)), 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		return fmt.Errorf("write counts: %w", err)
+	}
+	for _, data := range []interface{}{m.Vertices, m.Indices, m.NonCollideVertices, m.NonCollideIndices} {
+		err = binary.Write(buf, binary.LittleEndian, data)
+		if err != nil {
+			return fmt.Errorf("write geometry: %w", err)
+		}
+	}
+
+	deflated := &bytes.Buffer{}
+	zw := zlib.NewWriter(deflated)
+	_, err = zw.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("deflate close: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, []uint32{mapVersion, uint32(deflated.Len()), uint32(buf.Len())})
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	_, err = w.Write(deflated.Bytes())
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// DecodeMap reads a version 2 map. Models, placeables and terrain are not supported
+func DecodeMap(r io.Reader) (*Map, error) {
+	header := make([]uint32, 3)
+	err := binary.Read(r, binary.LittleEndian, header)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if header[0] != mapVersion {
+		return nil, fmt.Errorf("unsupported map version 0x%x", header[0])
+	}
+	zr, err := zlib.NewReader(io.LimitReader(r, int64(header[1])))
+	if err != nil {
+		return nil, fmt.Errorf("inflate: %w", err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, int64(header[2])))
+	if err != nil {
+		return nil, fmt.Errorf("inflate: %w", err)
+	}
+	if len(data) < 40 {
+		return nil, fmt.Errorf("inflated %d bytes, too small for counts", len(data))
+	}
+	counts := make([]uint32, 10)
+	for i := range counts {
+		counts[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	if counts[4] != 0 || counts[5] != 0 || counts[7] != 0 {
+		return nil, fmt.Errorf("models, placeables and terrain are not supported")
+	}
+	if uint64(counts[0])*12+uint64(counts[1])*4+uint64(counts[2])*12+uint64(counts[3])*4 > uint64(len(data)-40) {
+		return nil, fmt.Errorf("counts pass the end of the %d inflated bytes", len(data))
+	}
+
+	m := &Map{
+		Vertices:           make([]math32.Vector3, counts[0]),
+		Indices:            make([]uint32, counts[1]),
+		NonCollideVertices: make([]math32.Vector3, counts[2]),
+		NonCollideIndices:  make([]uint32, counts[3]),
+	}
+	br := bytes.NewReader(data[40:])
+	for _, data := range []interface{}{m.Vertices, m.Indices, m.NonCollideVertices, m.NonCollideIndices} {
+		err = binary.Read(br, binary.LittleEndian, data)
+		if err != nil {
+			return nil, fmt.Errorf("read geometry: %w", err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,162 @@
+package eqemu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// waterMagic starts every EQEmu water map
+const waterMagic = "EQEMUWATER"
+
+// waterVersion is the water map version written, a bsp tree with region types on its leaves
+const waterVersion = 1
+
+// WaterMap is an EQEmu .wtr file, the zone bsp tree with each leaf marked water, lava, pvp and such
+type WaterMap struct {
+	Nodes []*WaterNode
+}
+
+// WaterNode is a node of a water map. Front and Back are node numbers counting from 1, both 0 on leaves
+type WaterNode struct {
+	Normal        math32.Vector3
+	SplitDistance float32
+	// Region is the region a leaf holds, counting from 1
+	Region int32
+	// Special is the region type of a leaf
+	Special fragment.RegionType
+	Front   int32
+	Back    int32
+}
+
+// waterNode is how a node is stored
+type waterNode struct {
+	Number        int32
+	Normal        [3]float32
+	SplitDistance float32
+	Region        int32
+	Special       int32
+	Front         int32
+	Back          int32
+}
+
+// BuildWaterMap marks the bsp tree of a zone with the region types of its 0x29 region flags
+func BuildWaterMap(zone *wld.Wld) (*WaterMap, error) {
+	tree := zone.BspTree()
+	if tree == nil {
+		return nil, fmt.Errorf("no bsp tree")
+	}
+	wm := &WaterMap{}
+	for _, node := range tree.Nodes {
+		// normals stay in wld coordinates, EQEmu swaps the point it looks up instead
+		wn := &WaterNode{
+			Normal:        node.Normal,
+			SplitDistance: node.SplitDistance,
+			Region:        int32(node.RegionID),
+			Front:         int32(node.Front),
+			Back:          int32(node.Back),
+		}
+		if node.RegionID > 0 {
+			types := zone.RegionTypes(int(node.RegionID) - 1)
+			if len(types) > 0 {
+				wn.Special = types[0]
+			}
+		}
+		wm.Nodes = append(wm.Nodes, wn)
+	}
+	return wm, nil
+}
+
+// RegionTypeAt returns the region type of a point in server coordinates, walking the tree the way EQEmu does
+func (wm *WaterMap) RegionTypeAt(point math32.Vector3) fragment.RegionType {
+	// like EQEmu, look up (y, x, z), the swap back to wld coordinates
+	point = serverPoint(point)
+	number := int32(1)
+	for steps := 0; steps <= len(wm.Nodes); steps++ {
+		if number < 1 || int(number) > len(wm.Nodes) {
+			return fragment.RegionTypeNormal
+		}
+		node := wm.Nodes[number-1]
+		if node.Front == 0 && node.Back == 0 {
+			return node.Special
+		}
+		distance := node.Normal.Dot(&point) + node.SplitDistance
+		if distance == 0 {
+			return fragment.RegionTypeNormal
+		}
+		if distance > 0 {
+			number = node.Front
+			continue
+		}
+		number = node.Back
+	}
+	return fragment.RegionTypeNormal
+}
+
+// Encode writes a version 1 water map
+func (wm *WaterMap) Encode(w io.Writer) error {
+	_, err := w.Write([]byte(waterMagic))
+	if err != nil {
+		return fmt.Errorf("write magic: %w", err)
+	}
+	err = binary.Write(w, binary.LittleEndian, []uint32{waterVersion, uint32(len(wm.Nodes))})
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	for i, node := range wm.Nodes {
+		err = binary.Write(w, binary.LittleEndian, &waterNode{
+			Number:        int32(i + 1),
+			Normal:        [3]float32{node.Normal.X, node.Normal.Y, node.Normal.Z},
+			SplitDistance: node.SplitDistance,
+			Region:        node.Region,
+			Special:       int32(node.Special),
+			Front:         node.Front,
+			Back:          node.Back,
+		})
+		if err != nil {
+			return fmt.Errorf("write node %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// DecodeWaterMap reads a version 1 water map
+func DecodeWaterMap(r io.Reader) (*WaterMap, error) {
+	magic := make([]byte, len(waterMagic))
+	_, err := io.ReadFull(r, magic)
+	if err != nil {
+		return nil, fmt.Errorf("read magic: %w", err)
+	}
+	if string(magic) != waterMagic {
+		return nil, fmt.Errorf("unknown magic %q", magic)
+	}
+	header := make([]uint32, 2)
+	err = binary.Read(r, binary.LittleEndian, header)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if header[0] != waterVersion {
+		return nil, fmt.Errorf("unsupported water map version %d", header[0])
+	}
+	wm := &WaterMap{}
+	for i := uint32(0); i < header[1]; i++ {
+		node := &waterNode{}
+		err = binary.Read(r, binary.LittleEndian, node)
+		if err != nil {
+			return nil, fmt.Errorf("read node %d: %w", i, err)
+		}
+		wm.Nodes = append(wm.Nodes, &WaterNode{
+			Normal:        math32.Vector3{X: node.Normal[0], Y: node.Normal[1], Z: node.Normal[2]},
+			SplitDistance: node.SplitDistance,
+			Region:        node.Region,
+			Special:       fragment.RegionType(node.Special),
+			Front:         node.Front,
+			Back:          node.Back,
+		})
+	}
+	return wm, nil
+}
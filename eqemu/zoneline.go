@@ -0,0 +1,53 @@
+package eqemu
+
+import (
+	"fmt"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld"
+)
+
+// ZoneLine is a zone line region and where it sends players
+type ZoneLine struct {
+	// Region is the bsp region index, counting from 0
+	Region int `json:"region"`
+	// Center and Radius bound the region, in server coordinates
+	Center math32.Vector3 `json:"center"`
+	Radius float32        `json:"radius"`
+	ZoneID int            `json:"zoneId"`
+	// PointIndex is the zone point used when ZoneID is 255, in place of Position and Heading
+	PointIndex int            `json:"pointIndex"`
+	Position   math32.Vector3 `json:"position"`
+	Heading    int            `json:"heading"`
+}
+
+// ZoneLines returns every region of a zone tagged as a zone line
+func ZoneLines(zone *wld.Wld) ([]*ZoneLine, error) {
+	regions := zone.Regions()
+	zoneLines := []*ZoneLine{}
+	for _, flag := range zone.RegionFlags() {
+		zl, err := flag.ZoneLine()
+		if err != nil {
+			return nil, fmt.Errorf("region flag %s: %w", flag.Name(), err)
+		}
+		if zl == nil {
+			continue
+		}
+		for _, index := range flag.RegionIndices {
+			if index < 0 || index >= len(regions) {
+				return nil, fmt.Errorf("region flag %s region %d out of range", flag.Name(), index)
+			}
+			sphere := regions[index].Sphere
+			zoneLines = append(zoneLines, &ZoneLine{
+				Region:     index,
+				Center:     serverPoint(sphere.Center),
+				Radius:     sphere.Radius,
+				ZoneID:     zl.ZoneID,
+				PointIndex: zl.PointIndex,
+				Position:   zl.Position,
+				Heading:    zl.Heading,
+			})
+		}
+	}
+	return zoneLines, nil
+}
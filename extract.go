@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runExtract(args []string) error {
+	fs := newFlagSet("extract", "[archives...]")
+	out := fs.String("out", "", "directory to place extracted _<name>.<ext>/ folders in (defaults to next to each archive)")
+	verbose := fs.Bool("v", false, "print every extracted file")
+	jobs := fs.Int("j", 0, "chunks to inflate at once (defaults to every cpu)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	paths, err := archivePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err = extract(path, *out, *verbose, *jobs)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// extractDir returns the _<name>.<ext>/ directory an archive extracts to
+func extractDir(path string, out string) string {
+	if out == "" {
+		out = filepath.Dir(path)
+	}
+	return filepath.Join(out, "_"+filepath.Base(path))
+}
+
+func extract(path string, out string, verbose bool, jobs int) error {
+	archive, err := openArchive(path, jobs)
+	if err != nil {
+		return err
+	}
+	outPath := extractDir(path, out)
+	err = os.MkdirAll(outPath, 0755)
+	if err != nil {
+		return fmt.Errorf("mkdirall %s: %w", outPath, err)
+	}
+	fmt.Println(outPath)
+	for _, entry := range archive.Files {
+		fPath := filepath.Join(outPath, entry.Name)
+		rel, err := filepath.Rel(outPath, fPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			fmt.Printf("%s: skipped, outside %s\n", entry.Name, outPath)
+			continue
+		}
+		if verbose {
+			fmt.Println(fPath)
+		}
+		err = ioutil.WriteFile(fPath, entry.Data, 0644)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", fPath, err)
+		}
+	}
+	return nil
+}
+
+// openArchive decodes the pfs archive at path, inflating up to jobs chunks at once
+func openArchive(path string, jobs int) (*pfs.Pfs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	archive, err := pfs.DecodeWithOptions(f, pfs.DecodeOptions{Concurrency: jobs})
+	if err != nil {
+		return nil, fmt.Errorf("load: %w", err)
+	}
+	return archive, nil
+}
@@ -0,0 +1,100 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// decodeBMP decodes an uncompressed 4, 8, 24 or 32 bit windows bitmap, the formats everquest textures use.
+// Paletted bitmaps decode to *image.Paletted, so the palette can be changed afterwards
+func decodeBMP(data []byte) (image.Image, error) {
+	if len(data) < 54 || string(data[0:2]) != "BM" {
+		return nil, fmt.Errorf("not a bitmap")
+	}
+	offset := binary.LittleEndian.Uint32(data[10:])
+	headerSize := binary.LittleEndian.Uint32(data[14:])
+	if headerSize < 40 {
+		return nil, fmt.Errorf("unsupported header size %d", headerSize)
+	}
+	width := int(int32(binary.LittleEndian.Uint32(data[18:])))
+	height := int(int32(binary.LittleEndian.Uint32(data[22:])))
+	bitCount := int(binary.LittleEndian.Uint16(data[28:]))
+	compression := binary.LittleEndian.Uint32(data[30:])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[46:]))
+	if compression != 0 {
+		return nil, fmt.Errorf("unsupported compression %d", compression)
+	}
+
+	// a negative height stores rows top to bottom, otherwise they are bottom to top
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	if width <= 0 || height <= 0 || width > 1<<15 || height > 1<<15 {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	stride := (width*bitCount + 31) / 32 * 4
+	if uint64(offset)+uint64(stride*height) > uint64(len(data)) {
+		return nil, fmt.Errorf("pixel data past end of file")
+	}
+	row := func(y int) []byte {
+		if !topDown {
+			y = height - 1 - y
+		}
+		start := int(offset) + y*stride
+		return data[start : start+stride]
+	}
+
+	switch bitCount {
+	case 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		paletteStart := 14 + int(headerSize)
+		if paletteStart+colorsUsed*4 > len(data) {
+			return nil, fmt.Errorf("palette past end of file")
+		}
+		palette := make(color.Palette, 1<<bitCount)
+		for i := range palette {
+			palette[i] = color.RGBA{A: 255}
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[paletteStart+i*4:]
+			palette[i] = color.RGBA{R: p[2], G: p[1], B: p[0], A: 255}
+		}
+		img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		for y := 0; y < height; y++ {
+			src := row(y)
+			dst := img.Pix[y*img.Stride:]
+			for x := 0; x < width; x++ {
+				if bitCount == 8 {
+					dst[x] = src[x]
+					continue
+				}
+				dst[x] = src[x/2] >> 4
+				if x%2 == 1 {
+					dst[x] = src[x/2] & 0x0F
+				}
+			}
+		}
+		return img, nil
+	case 24, 32:
+		// the fourth byte of 32 bit pixels is unused without bitfields, so every pixel is opaque
+		size := bitCount / 8
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			src := row(y)
+			dst := img.Pix[y*img.Stride:]
+			for x := 0; x < width; x++ {
+				dst[x*4] = src[x*size+2]
+				dst[x*4+1] = src[x*size+1]
+				dst[x*4+2] = src[x*size]
+				dst[x*4+3] = 255
+			}
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("unsupported bit count %d", bitCount)
+}
@@ -0,0 +1,188 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+const (
+	ddsPixelFormatAlpha  = 0x1
+	ddsPixelFormatFourCC = 0x4
+	ddsPixelFormatRGB    = 0x40
+)
+
+// decodeDDS decodes the largest mipmap of a DXT1, DXT3, DXT5 or uncompressed rgb direct draw surface.
+// Many everquest textures named .bmp are direct draw surfaces
+func decodeDDS(data []byte) (image.Image, error) {
+	if len(data) < 128 || string(data[0:4]) != "DDS " {
+		return nil, fmt.Errorf("not a direct draw surface")
+	}
+	height := int(binary.LittleEndian.Uint32(data[12:]))
+	width := int(binary.LittleEndian.Uint32(data[16:]))
+	if width <= 0 || height <= 0 || width > 1<<15 || height > 1<<15 {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	flags := binary.LittleEndian.Uint32(data[80:])
+	fourCC := string(data[84:88])
+	pixels := data[128:]
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	if flags&ddsPixelFormatFourCC == 0 {
+		if flags&ddsPixelFormatRGB == 0 {
+			return nil, fmt.Errorf("unsupported pixel format flags 0x%x", flags)
+		}
+		bitCount := int(binary.LittleEndian.Uint32(data[88:]))
+		masks := [4]uint32{
+			binary.LittleEndian.Uint32(data[92:]),
+			binary.LittleEndian.Uint32(data[96:]),
+			binary.LittleEndian.Uint32(data[100:]),
+			binary.LittleEndian.Uint32(data[104:]),
+		}
+		if flags&ddsPixelFormatAlpha == 0 {
+			masks[3] = 0
+		}
+		return img, decodeDDSRGB(img, pixels, bitCount, masks)
+	}
+
+	blockSize := 16
+	if fourCC == "DXT1" {
+		blockSize = 8
+	} else if fourCC != "DXT3" && fourCC != "DXT5" {
+		return nil, fmt.Errorf("unsupported format %q", fourCC)
+	}
+	blocksWide := (width + 3) / 4
+	blocksHigh := (height + 3) / 4
+	if len(pixels) < blocksWide*blocksHigh*blockSize {
+		return nil, fmt.Errorf("pixel data past end of file")
+	}
+	var block [16][4]uint8
+	for by := 0; by < blocksHigh; by++ {
+		for bx := 0; bx < blocksWide; bx++ {
+			src := pixels[(by*blocksWide+bx)*blockSize:]
+			switch fourCC {
+			case "DXT1":
+				dxtColors(&block, src, true)
+			case "DXT3":
+				dxtColors(&block, src[8:], false)
+				for i := 0; i < 16; i++ {
+					// 4 bits of alpha per pixel
+					a := src[i/2] >> (uint(i%2) * 4) & 0x0F
+					block[i][3] = a * 17
+				}
+			case "DXT5":
+				dxtColors(&block, src[8:], false)
+				dxt5Alpha(&block, src)
+			}
+			for i := 0; i < 16; i++ {
+				x := bx*4 + i%4
+				y := by*4 + i/4
+				if x >= width || y >= height {
+					continue
+				}
+				copy(img.Pix[y*img.Stride+x*4:], block[i][:])
+			}
+		}
+	}
+	return img, nil
+}
+
+// dxtColors decodes the colors of a 4x4 block, two rgb565 end points and 2 bit indices.
+// A DXT1 block whose first end point is not the larger has 3 colors and transparent black
+func dxtColors(block *[16][4]uint8, src []byte, dxt1 bool) {
+	c0 := binary.LittleEndian.Uint16(src)
+	c1 := binary.LittleEndian.Uint16(src[2:])
+	indices := binary.LittleEndian.Uint32(src[4:])
+
+	var colors [4][4]int
+	colors[0] = rgb565(c0)
+	colors[1] = rgb565(c1)
+	for i := 0; i < 3; i++ {
+		if c0 > c1 || !dxt1 {
+			colors[2][i] = (2*colors[0][i] + colors[1][i]) / 3
+			colors[3][i] = (colors[0][i] + 2*colors[1][i]) / 3
+			continue
+		}
+		colors[2][i] = (colors[0][i] + colors[1][i]) / 2
+	}
+	colors[2][3] = 255
+	colors[3][3] = 255
+	if c0 <= c1 && dxt1 {
+		colors[3][3] = 0
+	}
+
+	for i := 0; i < 16; i++ {
+		c := colors[indices>>(uint(i)*2)&0x3]
+		block[i] = [4]uint8{uint8(c[0]), uint8(c[1]), uint8(c[2]), uint8(c[3])}
+	}
+}
+
+// dxt5Alpha decodes the alpha of a 4x4 block, two end points and 3 bit indices
+func dxt5Alpha(block *[16][4]uint8, src []byte) {
+	a0 := int(src[0])
+	a1 := int(src[1])
+	var alphas [8]int
+	alphas[0] = a0
+	alphas[1] = a1
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			alphas[i+1] = ((7-i)*a0 + i*a1) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			alphas[i+1] = ((5-i)*a0 + i*a1) / 5
+		}
+		alphas[6] = 0
+		alphas[7] = 255
+	}
+	var indices uint64
+	for i := 0; i < 6; i++ {
+		indices |= uint64(src[2+i]) << (uint(i) * 8)
+	}
+	for i := 0; i < 16; i++ {
+		block[i][3] = uint8(alphas[indices>>(uint(i)*3)&0x7])
+	}
+}
+
+// rgb565 expands a packed 16 bit color to 8 bits per channel
+func rgb565(c uint16) [4]int {
+	r := int(c>>11) & 0x1F
+	g := int(c>>5) & 0x3F
+	b := int(c) & 0x1F
+	return [4]int{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// decodeDDSRGB decodes uncompressed pixels, with each channel found by its mask
+func decodeDDSRGB(img *image.NRGBA, pixels []byte, bitCount int, masks [4]uint32) error {
+	size := bitCount / 8
+	if size < 2 || size > 4 || bitCount%8 != 0 {
+		return fmt.Errorf("unsupported bit count %d", bitCount)
+	}
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+	if len(pixels) < width*height*size {
+		return fmt.Errorf("pixel data past end of file")
+	}
+	for i := 0; i < width*height; i++ {
+		var value uint32
+		for j := 0; j < size; j++ {
+			value |= uint32(pixels[i*size+j]) << (uint(j) * 8)
+		}
+		for channel, mask := range masks {
+			if mask == 0 {
+				img.Pix[i*4+channel] = 255
+				continue
+			}
+			img.Pix[i*4+channel] = maskChannel(value, mask)
+		}
+	}
+	return nil
+}
+
+// maskChannel scales the bits of value under mask to 8 bits
+func maskChannel(value uint32, mask uint32) uint8 {
+	shift := uint(bits.TrailingZeros32(mask))
+	max := mask >> shift
+	return uint8(uint64(value&mask>>shift) * 255 / uint64(max))
+}
@@ -0,0 +1,431 @@
+package gltf
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// ToWld converts every mesh placed by the default scene to a mesh fragment, sharing one material list
+// with a material per document material, and bitmap fragments naming each texture file.
+// Node transforms are applied, each mesh is centered on its place in the scene
+// and quantized to the precision wld meshes are stored with
+func ToWld(g *GLTF) (*wld.Wld, error) {
+	doc := g.Document
+	if len(doc.Scenes) == 0 {
+		return nil, fmt.Errorf("no scene")
+	}
+	scene := doc.Scenes[0]
+	if doc.Scene != nil && int(*doc.Scene) < len(doc.Scenes) {
+		scene = doc.Scenes[*doc.Scene]
+	}
+
+	im := &wldImporter{doc: doc, w: &wld.Wld{IsOldWorld: true}}
+	list := &fragment.MaterialList{}
+	for i, m := range doc.Materials {
+		name := m.Name
+		if name == "" {
+			name = fmt.Sprintf("MATERIAL%d", i)
+		}
+		list.MaterialReferences = append(list.MaterialReferences, im.addMaterial(m, name))
+	}
+	// primitives without a material are drawn with a plain one added after the rest
+	for _, m := range doc.Meshes {
+		for _, primitive := range m.Primitives {
+			if primitive.Material == nil && im.defaultMaterial == 0 {
+				list.MaterialReferences = append(list.MaterialReferences, im.addMaterial(&gltf.Material{}, "DEFAULT"))
+				im.defaultMaterial = len(list.MaterialReferences)
+			}
+		}
+	}
+	im.materialList = im.w.Add(list)
+
+	var walk func(index uint32, parent *math32.Matrix4) error
+	walk = func(index uint32, parent *math32.Matrix4) error {
+		if int(index) >= len(doc.Nodes) {
+			return fmt.Errorf("node %d of %d", index, len(doc.Nodes))
+		}
+		node := doc.Nodes[index]
+		local := nodeMatrix(node)
+		var world math32.Matrix4
+		world.MultiplyMatrices(parent, &local)
+		if node.Mesh != nil {
+			name := node.Name
+			if name == "" {
+				name = fmt.Sprintf("MESH%d", *node.Mesh)
+			}
+			err := im.addMesh(*node.Mesh, name, &world)
+			if err != nil {
+				return fmt.Errorf("node %s: %w", name, err)
+			}
+		}
+		for _, child := range node.Children {
+			err := walk(child, &world)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, index := range scene.Nodes {
+		err := walk(index, math32.NewMatrix4())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return im.w, nil
+}
+
+// wldImporter tracks the wld being built from a document
+type wldImporter struct {
+	doc          *gltf.Document
+	w            *wld.Wld
+	materialList uint32
+	// defaultMaterial is the material list index of the plain material plus 1, 0 if not needed
+	defaultMaterial int
+}
+
+// fragmentName uppercases name and adds the suffix wld fragments of a type are named with
+func fragmentName(name string, suffix string) string {
+	name = strings.ToUpper(strings.ReplaceAll(name, " ", "_"))
+	if strings.HasSuffix(name, suffix) {
+		return name
+	}
+	return name + suffix
+}
+
+// addMaterial adds a material, and the bitmap fragments of its base color texture, returning its reference
+func (im *wldImporter) addMaterial(m *gltf.Material, name string) uint32 {
+	name = fragmentName(name, "_MDF")
+	mat := &fragment.Material{MaterialType: fragment.MaterialTypeDiffuse, ShaderType: fragment.ShaderTypeDiffuse}
+	switch m.AlphaMode {
+	case gltf.AlphaMask:
+		mat.MaterialType = fragment.MaterialTypeTransparentMasked
+		mat.ShaderType = fragment.ShaderTypeTransparentMasked
+	case gltf.AlphaBlend:
+		mat.MaterialType = fragment.MaterialTypeTransparent50
+		mat.ShaderType = fragment.ShaderTypeTransparent50
+	}
+	file := im.textureFile(m)
+	if file != "" {
+		bitmapName := &fragment.BitmapName{Files: []string{file}}
+		info := &fragment.BitmapInfo{BitmapNameReferences: []uint32{im.w.Add(bitmapName)}}
+		info.SetName(strings.TrimSuffix(name, "_MDF") + "_SPRITE")
+		mat.BitmapInfoReference = im.w.Add(&fragment.BitmapInfoReference{Reference: im.w.Add(info)})
+	}
+	mat.SetName(name)
+	return im.w.Add(mat)
+}
+
+// textureFile is the file name of the base color texture of m, empty if it has none
+func (im *wldImporter) textureFile(m *gltf.Material) string {
+	if m.PBRMetallicRoughness == nil || m.PBRMetallicRoughness.BaseColorTexture == nil {
+		return ""
+	}
+	index := m.PBRMetallicRoughness.BaseColorTexture.Index
+	if int(index) >= len(im.doc.Textures) {
+		return ""
+	}
+	texture := im.doc.Textures[index]
+	if texture.Source == nil || int(*texture.Source) >= len(im.doc.Images) {
+		return ""
+	}
+	img := im.doc.Images[*texture.Source]
+	// embedded images have no file, but are named after it when exported by FromWld
+	if img.URI != "" && !img.IsEmbeddedResource() {
+		return path.Base(img.URI)
+	}
+	return img.Name
+}
+
+// addMesh converts the primitives of a document mesh to one mesh fragment placed by world
+func (im *wldImporter) addMesh(index uint32, name string, world *math32.Matrix4) error {
+	if int(index) >= len(im.doc.Meshes) {
+		return fmt.Errorf("mesh %d of %d", index, len(im.doc.Meshes))
+	}
+	mesh := &fragment.Mesh{MaterialReference: im.materialList}
+	mesh.SetName(fragmentName(name, "_DMSPRITEDEF"))
+	hasNormals := false
+	hasColors := false
+	materials := []int{}
+	// primitives often share vertices, which are only added once
+	bases := make(map[string]int)
+	counts := make(map[string]int)
+	// a mirroring transform turns triangles inside out
+	flip := world.Determinant() < 0
+
+	for i, primitive := range im.doc.Meshes[index].Primitives {
+		if primitive.Mode != gltf.PrimitiveTriangles {
+			return fmt.Errorf("primitive %d is not triangles", i)
+		}
+		position, ok := primitive.Attributes[gltf.POSITION]
+		if !ok {
+			return fmt.Errorf("primitive %d has no positions", i)
+		}
+		material := im.defaultMaterial - 1
+		if primitive.Material != nil {
+			material = int(*primitive.Material)
+			if material >= len(im.doc.Materials) {
+				return fmt.Errorf("primitive %d material %d of %d", i, material, len(im.doc.Materials))
+			}
+		}
+
+		key := fmt.Sprint(position, attribute(primitive, gltf.NORMAL), attribute(primitive, gltf.TEXCOORD_0), attribute(primitive, gltf.COLOR_0))
+		base, ok := bases[key]
+		if !ok {
+			base = len(mesh.Verticies)
+			bases[key] = base
+			err := im.addVertices(mesh, primitive, world)
+			if err != nil {
+				return fmt.Errorf("primitive %d: %w", i, err)
+			}
+			counts[key] = len(mesh.Verticies) - base
+			_, ok = primitive.Attributes[gltf.NORMAL]
+			hasNormals = hasNormals || ok
+			_, ok = primitive.Attributes[gltf.COLOR_0]
+			hasColors = hasColors || ok
+		}
+		count := counts[key]
+
+		var indices []uint32
+		if primitive.Indices != nil {
+			var err error
+			indices, err = modeler.ReadIndices(im.doc, im.doc.Accessors[*primitive.Indices], nil)
+			if err != nil {
+				return fmt.Errorf("primitive %d indices: %w", i, err)
+			}
+		} else {
+			for j := 0; j < count; j++ {
+				indices = append(indices, uint32(j))
+			}
+		}
+		for j := 0; j+2 < len(indices); j += 3 {
+			p := &fragment.Polygon{IsSolid: true, Vertex1: base + int(indices[j]), Vertex2: base + int(indices[j+1]), Vertex3: base + int(indices[j+2])}
+			if flip {
+				p.Vertex2, p.Vertex3 = p.Vertex3, p.Vertex2
+			}
+			for _, v := range []int{p.Vertex1, p.Vertex2, p.Vertex3} {
+				if v >= base+count {
+					return fmt.Errorf("primitive %d index %d of %d vertices", i, v-base, count)
+				}
+			}
+			mesh.Indices = append(mesh.Indices, p)
+			materials = append(materials, material)
+		}
+	}
+	if len(mesh.Verticies) > math.MaxUint16 {
+		return fmt.Errorf("%d vertices, polygons index at most %d", len(mesh.Verticies), math.MaxUint16)
+	}
+	if !hasNormals {
+		mesh.Normals = nil
+	}
+	if !hasColors {
+		mesh.Colors = nil
+	}
+
+	sortByMaterial(mesh, materials)
+	center(mesh)
+	err := mesh.Quantize()
+	if err != nil {
+		return err
+	}
+	im.w.Add(mesh)
+	return nil
+}
+
+// nodeMatrix is the local transform of node, from its matrix or its translation, rotation and scale
+func nodeMatrix(node *gltf.Node) math32.Matrix4 {
+	if node.MatrixOrDefault() != gltf.DefaultMatrix {
+		return math32.Matrix4(node.Matrix)
+	}
+	t := node.TranslationOrDefault()
+	r := node.RotationOrDefault()
+	s := node.ScaleOrDefault()
+	var m math32.Matrix4
+	m.Compose(&math32.Vector3{X: t[0], Y: t[1], Z: t[2]}, &math32.Quaternion{X: r[0], Y: r[1], Z: r[2], W: r[3]}, &math32.Vector3{X: s[0], Y: s[1], Z: s[2]})
+	return m
+}
+
+// attribute returns the accessor of an attribute of primitive, -1 if it has none
+func attribute(primitive *gltf.Primitive, name string) int {
+	index, ok := primitive.Attributes[name]
+	if !ok {
+		return -1
+	}
+	return int(index)
+}
+
+// addVertices appends the vertices of primitive to mesh, moved by world into wld space.
+// Attributes the primitive lacks are zero, so every vertex has a uv, normal and color
+func (im *wldImporter) addVertices(mesh *fragment.Mesh, primitive *gltf.Primitive, world *math32.Matrix4) error {
+	positions, err := modeler.ReadPosition(im.doc, im.doc.Accessors[primitive.Attributes[gltf.POSITION]], nil)
+	if err != nil {
+		return fmt.Errorf("positions: %w", err)
+	}
+	var normals [][3]float32
+	if index, ok := primitive.Attributes[gltf.NORMAL]; ok {
+		normals, err = modeler.ReadNormal(im.doc, im.doc.Accessors[index], nil)
+		if err != nil {
+			return fmt.Errorf("normals: %w", err)
+		}
+	}
+	var uvs [][2]float32
+	if index, ok := primitive.Attributes[gltf.TEXCOORD_0]; ok {
+		uvs, err = modeler.ReadTextureCoord(im.doc, im.doc.Accessors[index], nil)
+		if err != nil {
+			return fmt.Errorf("uvs: %w", err)
+		}
+	}
+	var colors [][4]uint8
+	if index, ok := primitive.Attributes[gltf.COLOR_0]; ok {
+		colors, err = modeler.ReadColor(im.doc, im.doc.Accessors[index], nil)
+		if err != nil {
+			return fmt.Errorf("colors: %w", err)
+		}
+	}
+
+	for i, p := range positions {
+		v := math32.Vector3{X: p[0], Y: p[1], Z: p[2]}
+		v.ApplyMatrix4(world)
+		mesh.Verticies = append(mesh.Verticies, math32.Vector3{X: v.X, Y: v.Z, Z: v.Y})
+
+		var n math32.Vector3
+		if i < len(normals) {
+			n = direction(math32.Vector3{X: normals[i][0], Y: normals[i][1], Z: normals[i][2]}, world)
+			n.Normalize()
+		}
+		mesh.Normals = append(mesh.Normals, math32.Vector3{X: n.X, Y: n.Z, Z: n.Y})
+
+		var uv math32.Vector2
+		if i < len(uvs) {
+			uv = math32.Vector2{X: uvs[i][0], Y: uvs[i][1]}
+		}
+		mesh.TextureUVCoordinates = append(mesh.TextureUVCoordinates, uv)
+
+		var c color.RGBA
+		if i < len(colors) {
+			c = color.RGBA{R: colors[i][0], G: colors[i][1], B: colors[i][2], A: colors[i][3]}
+		}
+		mesh.Colors = append(mesh.Colors, c)
+	}
+	return nil
+}
+
+// sortByMaterial orders polygons, and then vertices, by material, and fills in the render groups and vertex textures
+// that wld meshes use to draw each run with its material
+func sortByMaterial(mesh *fragment.Mesh, materials []int) {
+	order := make([]int, len(mesh.Indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return materials[order[i]] < materials[order[j]]
+	})
+	polygons := make([]*fragment.Polygon, len(order))
+	polygonMaterials := make([]int, len(order))
+	for i, j := range order {
+		polygons[i] = mesh.Indices[j]
+		polygonMaterials[i] = materials[j]
+	}
+	mesh.Indices = polygons
+
+	// a vertex takes the material of the first polygon using it, unused vertices go last
+	vertexMaterials := make([]int, len(mesh.Verticies))
+	for i := range vertexMaterials {
+		vertexMaterials[i] = math.MaxInt32
+	}
+	for i, p := range polygons {
+		for _, v := range []int{p.Vertex1, p.Vertex2, p.Vertex3} {
+			if vertexMaterials[v] == math.MaxInt32 {
+				vertexMaterials[v] = polygonMaterials[i]
+			}
+		}
+	}
+	vertexOrder := make([]int, len(mesh.Verticies))
+	for i := range vertexOrder {
+		vertexOrder[i] = i
+	}
+	sort.SliceStable(vertexOrder, func(i, j int) bool {
+		return vertexMaterials[vertexOrder[i]] < vertexMaterials[vertexOrder[j]]
+	})
+	remap := make([]int, len(vertexOrder))
+	vertices := make([]math32.Vector3, len(vertexOrder))
+	uvs := make([]math32.Vector2, len(vertexOrder))
+	normals := []math32.Vector3{}
+	colors := []color.RGBA{}
+	for i, j := range vertexOrder {
+		remap[j] = i
+		vertices[i] = mesh.Verticies[j]
+		uvs[i] = mesh.TextureUVCoordinates[j]
+		if mesh.Normals != nil {
+			normals = append(normals, mesh.Normals[j])
+		}
+		if mesh.Colors != nil {
+			colors = append(colors, mesh.Colors[j])
+		}
+	}
+	mesh.Verticies = vertices
+	mesh.TextureUVCoordinates = uvs
+	if mesh.Normals != nil {
+		mesh.Normals = normals
+	}
+	if mesh.Colors != nil {
+		mesh.Colors = colors
+	}
+	for _, p := range polygons {
+		p.Vertex1 = remap[p.Vertex1]
+		p.Vertex2 = remap[p.Vertex2]
+		p.Vertex3 = remap[p.Vertex3]
+	}
+
+	for i, material := range polygonMaterials {
+		if i == 0 || polygonMaterials[i-1] != material {
+			mesh.RenderGroups = append(mesh.RenderGroups, &fragment.MeshRenderGroup{MaterialIndex: material})
+		}
+		mesh.RenderGroups[len(mesh.RenderGroups)-1].PolygonCount++
+	}
+	last := 0
+	if len(polygonMaterials) > 0 {
+		last = polygonMaterials[len(polygonMaterials)-1]
+	}
+	for i, j := range vertexOrder {
+		material := vertexMaterials[j]
+		if material == math.MaxInt32 {
+			material = last
+		}
+		if i == 0 || mesh.VertexTextures[len(mesh.VertexTextures)-1].MaterialIndex != material {
+			mesh.VertexTextures = append(mesh.VertexTextures, &fragment.MeshVertexTexture{MaterialIndex: material})
+		}
+		mesh.VertexTextures[len(mesh.VertexTextures)-1].VertexCount++
+	}
+}
+
+// center moves the vertices of mesh to be relative to the middle of their bounds, which becomes Center
+func center(mesh *fragment.Mesh) {
+	if len(mesh.Verticies) == 0 {
+		return
+	}
+	mesh.MinPosition = mesh.Verticies[0]
+	mesh.MaxPosition = mesh.Verticies[0]
+	for _, v := range mesh.Verticies {
+		mesh.MinPosition.Min(&v)
+		mesh.MaxPosition.Max(&v)
+	}
+	mesh.Center = mesh.MinPosition
+	mesh.Center.Add(&mesh.MaxPosition).MultiplyScalar(0.5)
+	mesh.MaxDistance = 0
+	for i := range mesh.Verticies {
+		v := &mesh.Verticies[i]
+		v.Sub(&mesh.Center)
+		mesh.MaxDistance = math32.Max(mesh.MaxDistance, v.Length())
+	}
+}
@@ -0,0 +1,80 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+func TestToWld(t *testing.T) {
+	zone := testZone(t)
+	want := zone.Fragments[5].(*fragment.Mesh)
+	g, err := FromWld(zone)
+	if err != nil {
+		t.Fatalf("from wld: %v", err)
+	}
+	w, err := ToWld(g)
+	if err != nil {
+		t.Fatalf("to wld: %v", err)
+	}
+
+	meshes := []*fragment.Mesh{}
+	for _, frag := range w.Fragments {
+		if mesh, ok := frag.(*fragment.Mesh); ok {
+			meshes = append(meshes, mesh)
+		}
+	}
+	if len(meshes) != 1 || meshes[0].Name() != "ZONE_DMSPRITEDEF" {
+		t.Fatalf("meshes %v", meshes)
+	}
+	mesh := meshes[0]
+	// the mesh is recentered on its bounds, but every vertex stays in place
+	if mesh.Center != (math32.Vector3{X: 15, Y: 25, Z: 35}) || mesh.Scale != 12 {
+		t.Fatalf("center %v scale %d", mesh.Center, mesh.Scale)
+	}
+	for i, v := range mesh.Verticies {
+		got := *v.Add(&mesh.Center)
+		wanted := want.Verticies[i]
+		wanted.Add(&want.Center)
+		if got != wanted {
+			t.Fatalf("vertex %d wanted %v, got %v", i, wanted, got)
+		}
+	}
+	if len(mesh.Normals) != 4 || len(mesh.TextureUVCoordinates) != 4 || mesh.Colors != nil {
+		t.Fatalf("%d normals %d uvs %d colors", len(mesh.Normals), len(mesh.TextureUVCoordinates), len(mesh.Colors))
+	}
+	if mesh.Normals[0] != want.Normals[0] || mesh.TextureUVCoordinates[3] != want.TextureUVCoordinates[3] {
+		t.Fatalf("normal %v uv %v", mesh.Normals[0], mesh.TextureUVCoordinates[3])
+	}
+	if len(mesh.Indices) != 2 || *mesh.Indices[1] != *want.Indices[1] {
+		t.Fatalf("polygons %v", mesh.Indices)
+	}
+	// both primitives are of one material, so they merge into one run
+	if len(mesh.RenderGroups) != 1 || mesh.RenderGroups[0].PolygonCount != 2 || len(mesh.VertexTextures) != 1 || mesh.VertexTextures[0].VertexCount != 4 {
+		t.Fatalf("render groups %v vertex textures %v", mesh.RenderGroups, mesh.VertexTextures)
+	}
+
+	list, ok := w.Fragment(mesh.MaterialReference).(*fragment.MaterialList)
+	if !ok || len(list.MaterialReferences) != 1 {
+		t.Fatalf("material list %v", w.Fragment(mesh.MaterialReference))
+	}
+	m, ok := w.Fragment(list.MaterialReferences[0]).(*fragment.Material)
+	if !ok || m.Name() != "SAND_MDF" || m.ShaderType != fragment.ShaderTypeDiffuse {
+		t.Fatalf("material %v", w.Fragment(list.MaterialReferences[0]))
+	}
+	if files := w.BitmapFiles(m); len(files) != 1 || files[0] != "sand.bmp" {
+		t.Fatalf("bitmap files %v", files)
+	}
+
+	// mirroring the node turns triangles around so they still face out
+	g.Document.Nodes[0].Scale = [3]float32{-1, 1, 1}
+	w, err = ToWld(g)
+	if err != nil {
+		t.Fatalf("to wld mirrored: %v", err)
+	}
+	mesh = w.Fragments[len(w.Fragments)-1].(*fragment.Mesh)
+	if p := mesh.Indices[0]; p.Vertex1 != 0 || p.Vertex2 != 2 || p.Vertex3 != 1 {
+		t.Fatalf("mirrored polygon %+v", p)
+	}
+}
@@ -3,14 +3,48 @@ package gltf
 import (
 	"fmt"
 	"io"
+	"io/fs"
 
 	"github.com/qmuntal/gltf"
 )
 
+// SaveOptions changes how a document is saved
+type SaveOptions struct {
+	// Binary writes a single .glb, with the buffer after the json instead of base64 inside it
+	Binary bool
+	// Images, if set, is where image uris are read from, such as a pfs.FS of the archive.
+	// Each image found is converted to png and embedded in the buffer, which changes the document
+	Images fs.FS
+}
+
 func Save(w io.WriteSeeker, g *GLTF) error {
+	return SaveWithOptions(w, g, SaveOptions{})
+}
+
+// SaveWithOptions writes g as a .gltf, or a .glb if opts.Binary is set
+func SaveWithOptions(w io.WriteSeeker, g *GLTF, opts SaveOptions) error {
+	if opts.Images != nil {
+		err := embedImages(g.Document, opts.Images)
+		if err != nil {
+			return fmt.Errorf("embed images: %w", err)
+		}
+	}
+
+	doc := *g.Document
+	if !opts.Binary {
+		// a .gltf has nowhere to keep buffer data besides the uri, so unnamed buffers are embedded
+		doc.Buffers = make([]*gltf.Buffer, len(g.Document.Buffers))
+		for i, buffer := range g.Document.Buffers {
+			b := *buffer
+			if b.URI == "" {
+				b.EmbeddedResource()
+			}
+			doc.Buffers[i] = &b
+		}
+	}
 	enc := gltf.NewEncoder(w)
-	enc.AsBinary = false
-	err := enc.Encode(g.Document)
+	enc.AsBinary = opts.Binary
+	err := enc.Encode(&doc)
 	if err != nil {
 		return fmt.Errorf("encode: %w", err)
 	}
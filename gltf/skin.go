@@ -0,0 +1,298 @@
+package gltf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// defaultFrameMs is the frame delay of animations that store none
+const defaultFrameMs = 100
+
+// FromActor builds a document of one animated actor, such as a character of a _chr archive.
+// The skeleton becomes a skin, its meshes are bound to it and every animation of it is added
+func FromActor(w *wld.Wld, actor *fragment.Actor) (*GLTF, error) {
+	if w.ActorSkeleton(actor) == nil {
+		return nil, fmt.Errorf("actor %s has no skeleton", actor.Name())
+	}
+	e := newWldExporter(w)
+	_, err := e.addActor(actor)
+	if err != nil {
+		return nil, fmt.Errorf("actor %s: %w", actor.Name(), err)
+	}
+	return &GLTF{Document: e.doc}, nil
+}
+
+// addActor adds the skeleton of actor as joint nodes and a skin, the meshes skinned and attached to it,
+// and its animations, returning the meshes it added
+func (e *wldExporter) addActor(actor *fragment.Actor) ([]*fragment.Mesh, error) {
+	skel := e.w.ActorSkeleton(actor)
+	if len(skel.Bones) == 0 {
+		return nil, nil
+	}
+	scene := e.doc.Scenes[0]
+	frameMs := uint32(0)
+	for _, ref := range actor.SpriteReferences {
+		if skelRef, ok := e.w.Fragment(ref).(*fragment.SkeletonReference); ok {
+			frameMs = skelRef.FrameMs
+		}
+	}
+
+	// the rest pose is the first frame of the track of each bone, relative to its parent
+	joints := make([]uint32, len(skel.Bones))
+	for i, bone := range skel.Bones {
+		node := &gltf.Node{Name: bone.Name}
+		track := e.w.BoneTrack(bone)
+		if track != nil && len(track.Frames) > 0 {
+			node.Translation, node.Rotation, node.Scale = transformTRS(track.Frames[0])
+		}
+		e.doc.Nodes = append(e.doc.Nodes, node)
+		joints[i] = uint32(len(e.doc.Nodes) - 1)
+	}
+	pose := make([]math32.Matrix4, len(skel.Bones))
+	posed := make([]bool, len(skel.Bones))
+	var walk func(i int, parent *math32.Matrix4)
+	walk = func(i int, parent *math32.Matrix4) {
+		if posed[i] {
+			return
+		}
+		posed[i] = true
+		local := boneMatrix(e.w.BoneTrack(skel.Bones[i]))
+		pose[i].MultiplyMatrices(parent, &local)
+		for _, child := range skel.Bones[i].Children {
+			e.doc.Nodes[joints[i]].Children = append(e.doc.Nodes[joints[i]].Children, joints[child])
+			walk(child, &pose[i])
+		}
+	}
+	for i, bone := range skel.Bones {
+		if bone.Parent == -1 {
+			walk(i, math32.NewMatrix4())
+			scene.Nodes = append(scene.Nodes, joints[i])
+		}
+	}
+
+	inverseBinds := make([][4][4]float32, len(skel.Bones))
+	for i := range pose {
+		if !posed[i] {
+			return nil, fmt.Errorf("bone %s is not reached from a root", skel.Bones[i].Name)
+		}
+		var inverse math32.Matrix4
+		err := inverse.GetInverse(&pose[i])
+		if err != nil {
+			return nil, fmt.Errorf("bone %s inverse: %w", skel.Bones[i].Name, err)
+		}
+		m := matrix(&inverse)
+		for j := range m {
+			inverseBinds[i][j/4][j%4] = m[j]
+		}
+	}
+	e.doc.Skins = append(e.doc.Skins, &gltf.Skin{
+		Name:                skel.Name(),
+		InverseBindMatrices: gltf.Index(modeler.WriteAccessor(e.doc, gltf.TargetNone, inverseBinds)),
+		Skeleton:            gltf.Index(joints[0]),
+		Joints:              joints,
+	})
+	skin := uint32(len(e.doc.Skins) - 1)
+
+	meshes := []*fragment.Mesh{}
+	for _, ref := range skel.MeshReferences {
+		mesh := e.meshOf(ref)
+		if mesh == nil {
+			continue
+		}
+		index, err := e.addMesh(mesh, pose)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %s: %w", mesh.Name(), err)
+		}
+		e.doc.Nodes = append(e.doc.Nodes, &gltf.Node{Name: mesh.Name(), Mesh: gltf.Index(index), Skin: gltf.Index(skin)})
+		scene.Nodes = append(scene.Nodes, uint32(len(e.doc.Nodes)-1))
+		meshes = append(meshes, mesh)
+	}
+	// older models attach a rigid mesh to each bone instead of skinning one
+	for i, bone := range skel.Bones {
+		mesh := e.meshOf(bone.MeshReference)
+		if mesh == nil {
+			continue
+		}
+		node, err := e.meshNode(mesh)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %s: %w", mesh.Name(), err)
+		}
+		e.doc.Nodes[joints[i]].Children = append(e.doc.Nodes[joints[i]].Children, node)
+		meshes = append(meshes, mesh)
+	}
+
+	if frameMs == 0 {
+		frameMs = defaultFrameMs
+	}
+	e.addAnimations(skel, joints, frameMs)
+	return meshes, nil
+}
+
+// meshOf returns the mesh a mesh reference points to, nil if ref is not one
+func (e *wldExporter) meshOf(ref uint32) *fragment.Mesh {
+	meshRef, ok := e.w.Fragment(ref).(*fragment.MeshReference)
+	if !ok {
+		return nil
+	}
+	mesh, ok := e.w.Fragment(meshRef.Reference).(*fragment.Mesh)
+	if !ok {
+		return nil
+	}
+	return mesh
+}
+
+// animationTrack is the track of one bone in an animation
+type animationTrack struct {
+	bone  int
+	ref   *fragment.TrackReference
+	track *fragment.Track
+}
+
+// addAnimations adds an animation for every prefix of the bone track names of skel, such as C01 for C01HUM_PE_TRACK.
+// Animations borrowed from another model, named by its prefix instead, are not found
+func (e *wldExporter) addAnimations(skel *fragment.SkeletonHierarchy, joints []uint32, frameMs uint32) {
+	restNames := make(map[string]int)
+	for i, bone := range skel.Bones {
+		ref, ok := e.w.Fragment(bone.TrackReference).(*fragment.TrackReference)
+		if ok && ref.Name() != "" {
+			restNames[strings.ToUpper(ref.Name())] = i
+		}
+	}
+
+	animations := make(map[string][]*animationTrack)
+	for _, frag := range e.w.Fragments {
+		ref, ok := frag.(*fragment.TrackReference)
+		if !ok || len(ref.Name()) <= 3 {
+			continue
+		}
+		name := strings.ToUpper(ref.Name())
+		bone, ok := restNames[name[3:]]
+		if !ok {
+			continue
+		}
+		track, ok := e.w.Fragment(ref.Reference).(*fragment.Track)
+		if !ok || len(track.Frames) == 0 {
+			continue
+		}
+		animations[name[:3]] = append(animations[name[:3]], &animationTrack{bone: bone, ref: ref, track: track})
+	}
+	names := []string{}
+	for name := range animations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tracks := animations[name]
+		// the delay is stored on some tracks of an animation but not others
+		delay := uint32(0)
+		for _, t := range tracks {
+			if t.ref.FrameMs > delay {
+				delay = t.ref.FrameMs
+			}
+		}
+		if delay == 0 {
+			delay = frameMs
+		}
+
+		anim := &gltf.Animation{Name: name}
+		inputs := make(map[int]uint32)
+		for _, t := range tracks {
+			frameCount := len(t.track.Frames)
+			input, ok := inputs[frameCount]
+			if !ok {
+				times := make([]float32, frameCount)
+				for i := range times {
+					times[i] = float32(i) * float32(delay) / 1000
+				}
+				input = modeler.WriteAccessor(e.doc, gltf.TargetNone, times)
+				e.doc.Accessors[input].Min = []float32{0}
+				e.doc.Accessors[input].Max = []float32{times[frameCount-1]}
+				inputs[frameCount] = input
+			}
+
+			translations := make([][3]float32, frameCount)
+			rotations := make([][4]float32, frameCount)
+			scales := make([][3]float32, frameCount)
+			for i, frame := range t.track.Frames {
+				translations[i], rotations[i], scales[i] = transformTRS(frame)
+			}
+			for _, channel := range []struct {
+				path gltf.TRSProperty
+				data interface{}
+			}{
+				{gltf.TRSTranslation, translations},
+				{gltf.TRSRotation, rotations},
+				{gltf.TRSScale, scales},
+			} {
+				anim.Samplers = append(anim.Samplers, &gltf.AnimationSampler{
+					Input:         gltf.Index(input),
+					Interpolation: gltf.InterpolationLinear,
+					Output:        gltf.Index(modeler.WriteAccessor(e.doc, gltf.TargetNone, channel.data)),
+				})
+				anim.Channels = append(anim.Channels, &gltf.Channel{
+					Sampler: gltf.Index(uint32(len(anim.Samplers) - 1)),
+					Target:  gltf.ChannelTarget{Node: gltf.Index(joints[t.bone]), Path: channel.path},
+				})
+			}
+		}
+		e.doc.Animations = append(e.doc.Animations, anim)
+	}
+}
+
+// boneScale is the uniform scale of a bone transform, frames without one are unscaled
+func boneScale(t *fragment.BoneTransform) float32 {
+	if t.Scale == 0 {
+		return 1
+	}
+	return t.Scale
+}
+
+// boneMatrix is the wld space matrix of the first frame of track, identity without one
+func boneMatrix(track *fragment.Track) math32.Matrix4 {
+	var m math32.Matrix4
+	m.Identity()
+	if track == nil || len(track.Frames) == 0 {
+		return m
+	}
+	t := track.Frames[0]
+	scale := boneScale(t)
+	m.Compose(&t.Translation, &t.Rotation, &math32.Vector3{X: scale, Y: scale, Z: scale})
+	return m
+}
+
+// transformTRS converts a bone transform to glTF node translation, rotation and scale.
+// Swapping y and z mirrors the rotation too, which reverses its angle around the swapped axis
+func transformTRS(t *fragment.BoneTransform) ([3]float32, [4]float32, [3]float32) {
+	scale := boneScale(t)
+	return point(t.Translation),
+		[4]float32{-t.Rotation.X, -t.Rotation.Z, -t.Rotation.Y, t.Rotation.W},
+		[3]float32{scale, scale, scale}
+}
+
+// matrix converts a wld space matrix to glTF, swapping the y and z rows and columns
+func matrix(m *math32.Matrix4) [16]float32 {
+	swap := [4]int{0, 2, 1, 3}
+	var out [16]float32
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			out[col*4+row] = m[swap[col]*4+swap[row]]
+		}
+	}
+	return out
+}
+
+// direction moves a normal by the rotation and scale of m, ignoring translation
+func direction(n math32.Vector3, m *math32.Matrix4) math32.Vector3 {
+	var origin math32.Vector3
+	n.ApplyMatrix4(m)
+	origin.ApplyMatrix4(m)
+	return *n.Sub(&origin)
+}
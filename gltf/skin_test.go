@@ -0,0 +1,101 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+func TestFromWldSkinned(t *testing.T) {
+	w := &wld.Wld{IsOldWorld: true}
+	// trackReference adds a track of frames and a reference to it named name
+	trackReference := func(name string, frameMs uint32, frames ...*fragment.BoneTransform) uint32 {
+		ref := &fragment.TrackReference{Reference: w.Add(&fragment.Track{Frames: frames}), FrameMs: frameMs}
+		ref.SetName(name)
+		return w.Add(ref)
+	}
+	rest := math32.Quaternion{W: 1}
+	// pelvis rest track, 2 up
+	pelvisTrack := trackReference("HUM_PE_TRACK", 0, &fragment.BoneTransform{Translation: math32.Vector3{Z: 2}, Rotation: rest, Scale: 1})
+	// head rest track, 1 above the pelvis
+	headTrack := trackReference("HUM_HE_TRACK", 0, &fragment.BoneTransform{Translation: math32.Vector3{Z: 1}, Rotation: rest, Scale: 1})
+	// C01 head track turning 90 degrees around z over 2 frames of 50ms
+	trackReference("C01HUM_HE_TRACK", 50,
+		&fragment.BoneTransform{Translation: math32.Vector3{Z: 1}, Rotation: rest, Scale: 1},
+		&fragment.BoneTransform{Translation: math32.Vector3{Z: 1}, Rotation: math32.Quaternion{Z: math32.Sqrt(0.5), W: math32.Sqrt(0.5)}, Scale: 1},
+	)
+	// mesh of a vertex on the pelvis and two on the head
+	mesh := &fragment.Mesh{
+		Verticies:    []math32.Vector3{{}, {X: 1}, {X: 2}},
+		Indices:      []*fragment.Polygon{{IsSolid: true, Vertex1: 0, Vertex2: 1, Vertex3: 2}},
+		VertexPieces: []*fragment.MeshVertexPiece{{Start: 0, Count: 1, Bone: 0}, {Start: 1, Count: 2, Bone: 1}},
+	}
+	mesh.SetName("HUM_DMSPRITEDEF")
+	meshRef := w.Add(&fragment.MeshReference{Reference: w.Add(mesh)})
+	// skeleton of a pelvis and a head child, skinning the mesh
+	skel := &fragment.SkeletonHierarchy{
+		Bones: []*fragment.SkeletonBone{
+			{Name: "HUM_PE_DAG", TrackReference: pelvisTrack, Parent: -1, Children: []int{1}},
+			{Name: "HUM_HE_DAG", TrackReference: headTrack, Parent: 0},
+		},
+		MeshReferences: []uint32{meshRef},
+	}
+	skel.SetName("HUM_HS_DEF")
+	// actor drawing the skeleton
+	actor := &fragment.Actor{
+		Actions:          []*fragment.ActorAction{{MinDistances: []float32{100}}},
+		SpriteReferences: []uint32{w.Add(&fragment.SkeletonReference{Reference: w.Add(skel)})},
+	}
+	actor.SetName("HUM_ACTORDEF")
+	w.Add(actor)
+
+	w = encodeZone(t, w)
+	g, err := FromWld(w)
+	if err != nil {
+		t.Fatalf("from wld: %v", err)
+	}
+	doc := g.Document
+
+	if len(doc.Skins) != 1 || len(doc.Skins[0].Joints) != 2 {
+		t.Fatalf("skins %+v", doc.Skins)
+	}
+	pelvis := doc.Nodes[doc.Skins[0].Joints[0]]
+	head := doc.Skins[0].Joints[1]
+	if pelvis.Name != "HUM_PE_DAG" || pelvis.Translation != [3]float32{0, 2, 0} {
+		t.Fatalf("pelvis %+v", pelvis)
+	}
+	if len(pelvis.Children) != 1 || pelvis.Children[0] != head {
+		t.Fatalf("pelvis children %v, wanted head %d", pelvis.Children, head)
+	}
+	if doc.Accessors[*doc.Skins[0].InverseBindMatrices].Count != 2 {
+		t.Fatalf("inverse bind matrices %d", doc.Accessors[*doc.Skins[0].InverseBindMatrices].Count)
+	}
+
+	// the mesh is skinned, not added again as a static mesh
+	if len(doc.Meshes) != 1 || len(doc.Nodes) != 3 || doc.Nodes[2].Skin == nil {
+		t.Fatalf("%d meshes %d nodes", len(doc.Meshes), len(doc.Nodes))
+	}
+	primitive := doc.Meshes[0].Primitives[0]
+	if _, ok := primitive.Attributes["JOINTS_0"]; !ok {
+		t.Fatalf("skinned mesh has no joints")
+	}
+	// vertices are moved to the rest pose of their bone, which puts the head vertices 3 up
+	positions := doc.Accessors[primitive.Attributes["POSITION"]]
+	if positions.Min[1] != 2 || positions.Max[0] != 2 || positions.Max[1] != 3 {
+		t.Fatalf("positions min %v max %v", positions.Min, positions.Max)
+	}
+
+	if len(doc.Animations) != 1 || doc.Animations[0].Name != "C01" {
+		t.Fatalf("animations %+v", doc.Animations)
+	}
+	anim := doc.Animations[0]
+	if len(anim.Channels) != 3 || *anim.Channels[0].Target.Node != head {
+		t.Fatalf("channels %d", len(anim.Channels))
+	}
+	input := doc.Accessors[*anim.Samplers[0].Input]
+	if input.Count != 2 || input.Max[0] != 0.05 {
+		t.Fatalf("keyframe times count %d max %v", input.Count, input.Max)
+	}
+}
@@ -0,0 +1,67 @@
+package gltf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// decodeTexture decodes a bitmap or direct draw surface, by its contents since either may be named .bmp
+func decodeTexture(data []byte) (image.Image, error) {
+	if bytes.HasPrefix(data, []byte("DDS ")) {
+		return decodeDDS(data)
+	}
+	return decodeBMP(data)
+}
+
+// embedImages replaces the uri of every image found in fsys with png data in the buffer.
+// Images missing from fsys keep their uri
+func embedImages(doc *gltf.Document, fsys fs.FS) error {
+	// masked materials treat the first palette color as transparent
+	masked := make(map[uint32]bool)
+	for _, m := range doc.Materials {
+		if m.AlphaMode != gltf.AlphaMask || m.PBRMetallicRoughness == nil || m.PBRMetallicRoughness.BaseColorTexture == nil {
+			continue
+		}
+		texture := doc.Textures[m.PBRMetallicRoughness.BaseColorTexture.Index]
+		if texture.Source != nil {
+			masked[*texture.Source] = true
+		}
+	}
+
+	for i, img := range doc.Images {
+		if img.URI == "" || img.IsEmbeddedResource() {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, img.URI)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", img.URI, err)
+		}
+		decoded, err := decodeTexture(data)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", img.URI, err)
+		}
+		if paletted, ok := decoded.(*image.Paletted); ok && masked[uint32(i)] && len(paletted.Palette) > 0 {
+			paletted.Palette[0] = color.RGBA{}
+		}
+		buf := &bytes.Buffer{}
+		err = png.Encode(buf, decoded)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", img.URI, err)
+		}
+		img.BufferView = gltf.Index(modeler.WriteBufferView(doc, gltf.TargetNone, buf.Bytes()))
+		img.MimeType = "image/png"
+		img.URI = ""
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// testBitmap is a 2x2 8 bit bitmap of palette 0 black, 1 red and 2 green
+func testBitmap() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("BM")
+	binary.Write(buf, binary.LittleEndian, []uint32{0, 0, 14 + 40 + 3*4})
+	binary.Write(buf, binary.LittleEndian, []uint32{40, 2, 2})
+	binary.Write(buf, binary.LittleEndian, []uint16{1, 8})
+	binary.Write(buf, binary.LittleEndian, []uint32{0, 0, 0, 0, 3, 0})
+	buf.Write([]byte{0, 0, 0, 0, 0, 0, 255, 0, 0, 255, 0, 0})
+	// rows are padded to 4 bytes, the bottom row comes first
+	buf.Write([]byte{2, 2, 0, 0, 0, 1, 0, 0})
+	return buf.Bytes()
+}
+
+func TestDecodeTexture(t *testing.T) {
+	img, err := decodeTexture(testBitmap())
+	if err != nil {
+		t.Fatalf("decode bitmap: %v", err)
+	}
+	if _, ok := img.(*image.Paletted); !ok {
+		t.Fatalf("bitmap wanted paletted, got %T", img)
+	}
+	for _, c := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, color.NRGBA{A: 255}},
+		{1, 0, color.NRGBA{R: 255, A: 255}},
+		{0, 1, color.NRGBA{G: 255, A: 255}},
+	} {
+		got := color.NRGBAModel.Convert(img.At(c.x, c.y))
+		if got != c.want {
+			t.Fatalf("bitmap %d,%d wanted %v, got %v", c.x, c.y, c.want, got)
+		}
+	}
+
+	// 4x4 DXT1, the end points are white and black with the top row white, the rest black
+	buf := &bytes.Buffer{}
+	buf.WriteString("DDS ")
+	header := make([]uint32, 31)
+	header[0] = 124
+	header[2] = 4
+	header[3] = 4
+	header[18] = 32
+	header[19] = ddsPixelFormatFourCC
+	binary.Write(buf, binary.LittleEndian, header)
+	copy(buf.Bytes()[84:], "DXT1")
+	binary.Write(buf, binary.LittleEndian, []uint16{0xFFFF, 0x0000})
+	binary.Write(buf, binary.LittleEndian, uint32(0x55555500))
+	img, err = decodeTexture(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decode dds: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 4 {
+		t.Fatalf("dds size %v", img.Bounds())
+	}
+	if got := color.NRGBAModel.Convert(img.At(3, 0)); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Fatalf("dds top row %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(2, 3)); got != (color.NRGBA{0, 0, 0, 255}) {
+		t.Fatalf("dds bottom row %v", got)
+	}
+}
+
+func TestSaveBinary(t *testing.T) {
+	doc := gltf.NewDocument()
+	modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}})
+	doc.Images = []*gltf.Image{{URI: "sand.bmp"}, {URI: "missing.bmp"}}
+	doc.Textures = []*gltf.Texture{{Source: gltf.Index(0)}}
+	doc.Materials = []*gltf.Material{{
+		AlphaMode:            gltf.AlphaMask,
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{BaseColorTexture: &gltf.TextureInfo{Index: 0}},
+	}}
+
+	path := filepath.Join(t.TempDir(), "zone.glb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	err = SaveWithOptions(f, &GLTF{Document: doc}, SaveOptions{
+		Binary: true,
+		Images: fstest.MapFS{"sand.bmp": &fstest.MapFile{Data: testBitmap()}},
+	})
+	f.Close()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	g, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	images := g.Document.Images
+	if len(images) != 2 || images[0].BufferView == nil || images[0].MimeType != "image/png" || images[0].URI != "" {
+		t.Fatalf("embedded image %+v", images[0])
+	}
+	if images[1].URI != "missing.bmp" {
+		t.Fatalf("missing image wanted its uri kept, got %+v", images[1])
+	}
+	view := g.Document.BufferViews[*images[0].BufferView]
+	data := g.Document.Buffers[view.Buffer].Data[view.ByteOffset : view.ByteOffset+view.ByteLength]
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	// the material is masked, so the first palette color is transparent
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Fatalf("masked pixel alpha %d", a)
+	}
+	if r, _, _, a := img.At(1, 0).RGBA(); r != 0xFFFF || a != 0xFFFF {
+		t.Fatalf("red pixel %v", img.At(1, 0))
+	}
+}
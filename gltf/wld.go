@@ -0,0 +1,235 @@
+package gltf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// FromWld builds a document with a node for every mesh of a wld, such as a zone.
+// Textures are referenced by the file names stored in the wld, so they are found beside the
+// document once the archive is extracted
+func FromWld(w *wld.Wld) (*GLTF, error) {
+	e := newWldExporter(w)
+	scene := e.doc.Scenes[0]
+
+	// meshes of animated actors are skinned to their skeleton, every other mesh gets a node of its own
+	skinned := make(map[*fragment.Mesh]bool)
+	for _, frag := range w.Fragments {
+		actor, ok := frag.(*fragment.Actor)
+		if !ok || w.ActorSkeleton(actor) == nil {
+			continue
+		}
+		meshes, err := e.addActor(actor)
+		if err != nil {
+			return nil, fmt.Errorf("actor %s: %w", actor.Name(), err)
+		}
+		for _, mesh := range meshes {
+			skinned[mesh] = true
+		}
+	}
+	for _, frag := range w.Fragments {
+		mesh, ok := frag.(*fragment.Mesh)
+		if !ok || skinned[mesh] {
+			continue
+		}
+		node, err := e.meshNode(mesh)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %s: %w", mesh.Name(), err)
+		}
+		scene.Nodes = append(scene.Nodes, node)
+	}
+	return &GLTF{Document: e.doc}, nil
+}
+
+// wldExporter tracks what has been added to a document, so shared materials and images are only added once
+type wldExporter struct {
+	w         *wld.Wld
+	doc       *gltf.Document
+	materials map[*fragment.Material]uint32
+	images    map[string]uint32
+}
+
+func newWldExporter(w *wld.Wld) *wldExporter {
+	return &wldExporter{
+		w:         w,
+		doc:       gltf.NewDocument(),
+		materials: make(map[*fragment.Material]uint32),
+		images:    make(map[string]uint32),
+	}
+}
+
+// point converts a wld position, which is left handed with z up, to glTF, which is right handed with y up.
+// Swapping y and z converts both, and keeps triangles facing the same way
+func point(v math32.Vector3) [3]float32 {
+	return [3]float32{v.X, v.Z, v.Y}
+}
+
+// meshNode adds a mesh and a node placing it at its center, returning the node index
+func (e *wldExporter) meshNode(mesh *fragment.Mesh) (uint32, error) {
+	index, err := e.addMesh(mesh, nil)
+	if err != nil {
+		return 0, err
+	}
+	e.doc.Nodes = append(e.doc.Nodes, &gltf.Node{
+		Name:        mesh.Name(),
+		Mesh:        gltf.Index(index),
+		Translation: point(mesh.Center),
+	})
+	return uint32(len(e.doc.Nodes) - 1), nil
+}
+
+// addMesh adds a mesh, returning its index.
+// If pose is set, the mesh is skinned: each vertex is moved by the bone matrix of its vertex piece and bound to that joint
+func (e *wldExporter) addMesh(mesh *fragment.Mesh, pose []math32.Matrix4) (uint32, error) {
+	vertexCount := len(mesh.Verticies)
+	bones := make([]int, vertexCount)
+	for _, piece := range mesh.VertexPieces {
+		if pose != nil && (piece.Bone < 0 || piece.Bone >= len(pose)) {
+			return 0, fmt.Errorf("vertex piece bone %d of %d", piece.Bone, len(pose))
+		}
+		for i := piece.Start; i < piece.Start+piece.Count && i < vertexCount; i++ {
+			bones[i] = piece.Bone
+		}
+	}
+
+	positions := make([][3]float32, vertexCount)
+	for i, v := range mesh.Verticies {
+		if pose != nil {
+			v.Add(&mesh.Center)
+			v.ApplyMatrix4(&pose[bones[i]])
+		}
+		positions[i] = point(v)
+	}
+	attributes := map[string]uint32{
+		gltf.POSITION: modeler.WritePosition(e.doc, positions),
+	}
+	if len(mesh.Normals) == vertexCount && vertexCount > 0 {
+		normals := make([][3]float32, vertexCount)
+		for i, n := range mesh.Normals {
+			if pose != nil {
+				n = direction(n, &pose[bones[i]])
+			}
+			n.Normalize()
+			normals[i] = point(n)
+		}
+		attributes[gltf.NORMAL] = modeler.WriteNormal(e.doc, normals)
+	}
+	if pose != nil && vertexCount > 0 {
+		joints := make([][4]uint16, vertexCount)
+		weights := make([][4]float32, vertexCount)
+		for i, bone := range bones {
+			joints[i] = [4]uint16{uint16(bone)}
+			weights[i] = [4]float32{1}
+		}
+		attributes[gltf.JOINTS_0] = modeler.WriteJoints(e.doc, joints)
+		attributes[gltf.WEIGHTS_0] = modeler.WriteWeights(e.doc, weights)
+	}
+	if len(mesh.TextureUVCoordinates) >= vertexCount && vertexCount > 0 {
+		uvs := make([][2]float32, vertexCount)
+		for i := range uvs {
+			uvs[i] = [2]float32{mesh.TextureUVCoordinates[i].X, mesh.TextureUVCoordinates[i].Y}
+		}
+		attributes[gltf.TEXCOORD_0] = modeler.WriteTextureCoord(e.doc, uvs)
+	}
+	if len(mesh.Colors) == vertexCount && vertexCount > 0 {
+		colors := make([][4]uint8, vertexCount)
+		for i, c := range mesh.Colors {
+			colors[i] = [4]uint8{c.R, c.G, c.B, c.A}
+		}
+		attributes[gltf.COLOR_0] = modeler.WriteColor(e.doc, colors)
+	}
+
+	// render groups are runs of polygons sharing a material, without them every polygon is one primitive
+	groups := mesh.RenderGroups
+	if len(groups) == 0 {
+		groups = []*fragment.MeshRenderGroup{{PolygonCount: len(mesh.Indices), MaterialIndex: -1}}
+	}
+	gm := &gltf.Mesh{Name: mesh.Name()}
+	start := 0
+	for _, group := range groups {
+		end := start + group.PolygonCount
+		if end > len(mesh.Indices) {
+			return 0, fmt.Errorf("render group passes polygon %d of %d", end, len(mesh.Indices))
+		}
+		if start == end {
+			continue
+		}
+		indices := make([]uint32, 0, (end-start)*3)
+		for i, p := range mesh.Indices[start:end] {
+			for _, index := range []int{p.Vertex1, p.Vertex2, p.Vertex3} {
+				if index < 0 || index >= vertexCount {
+					return 0, fmt.Errorf("polygon %d vertex %d out of range", start+i, index)
+				}
+				indices = append(indices, uint32(index))
+			}
+		}
+		primitive := &gltf.Primitive{
+			Attributes: attributes,
+			Indices:    gltf.Index(modeler.WriteIndices(e.doc, indices)),
+		}
+		material, ok := e.material(mesh, group.MaterialIndex)
+		if ok {
+			primitive.Material = gltf.Index(material)
+		}
+		gm.Primitives = append(gm.Primitives, primitive)
+		start = end
+	}
+	e.doc.Meshes = append(e.doc.Meshes, gm)
+	return uint32(len(e.doc.Meshes) - 1), nil
+}
+
+// material returns the document material of index in the material list of mesh, adding it if needed
+func (e *wldExporter) material(mesh *fragment.Mesh, index int) (uint32, bool) {
+	list, ok := e.w.Fragment(mesh.MaterialReference).(*fragment.MaterialList)
+	if !ok || index < 0 || index >= len(list.MaterialReferences) {
+		return 0, false
+	}
+	m, ok := e.w.Fragment(list.MaterialReferences[index]).(*fragment.Material)
+	if !ok {
+		return 0, false
+	}
+	if i, ok := e.materials[m]; ok {
+		return i, true
+	}
+
+	gm := &gltf.Material{
+		Name:                 m.Name(),
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{MetallicFactor: gltf.Float(0)},
+	}
+	switch m.ShaderType {
+	case fragment.ShaderTypeTransparentMasked:
+		gm.AlphaMode = gltf.AlphaMask
+	case fragment.ShaderTypeTransparent25, fragment.ShaderTypeTransparent50, fragment.ShaderTypeTransparent75,
+		fragment.ShaderTypeTransparentAdditive, fragment.ShaderTypeTransparentAdditiveUnlit,
+		fragment.ShaderTypeTransparentSkydome, fragment.ShaderTypeTransparentAdditiveUnlitSkydome:
+		gm.AlphaMode = gltf.AlphaBlend
+	}
+	// animated materials use their first frame
+	files := e.w.BitmapFiles(m)
+	if len(files) > 0 && m.ShaderType != fragment.ShaderTypeInvisible {
+		gm.PBRMetallicRoughness.BaseColorTexture = &gltf.TextureInfo{Index: e.texture(files[0])}
+	}
+	e.doc.Materials = append(e.doc.Materials, gm)
+	i := uint32(len(e.doc.Materials) - 1)
+	e.materials[m] = i
+	return i, true
+}
+
+// texture returns a texture of the image file, adding both if needed
+func (e *wldExporter) texture(file string) uint32 {
+	file = strings.ToLower(file)
+	if i, ok := e.images[file]; ok {
+		return i
+	}
+	e.doc.Images = append(e.doc.Images, &gltf.Image{Name: file, URI: file})
+	e.doc.Textures = append(e.doc.Textures, &gltf.Texture{Source: gltf.Index(uint32(len(e.doc.Images) - 1))})
+	i := uint32(len(e.doc.Textures) - 1)
+	e.images[file] = i
+	return i
+}
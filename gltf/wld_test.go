@@ -0,0 +1,128 @@
+package gltf
+
+import (
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/qmuntal/gltf"
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// testZone returns a wld of a mesh of two triangles, each its own render group of one textured material
+func testZone(t *testing.T) *wld.Wld {
+	w := &wld.Wld{IsOldWorld: true}
+	info := &fragment.BitmapInfo{BitmapNameReferences: []uint32{w.Add(&fragment.BitmapName{Files: []string{"SAND.BMP"}})}}
+	material := &fragment.Material{
+		BitmapInfoReference: w.Add(&fragment.BitmapInfoReference{Reference: w.Add(info)}),
+		Color:               color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		Brightness:          1,
+		ScaledAmbient:       1,
+		MaterialType:        fragment.MaterialTypeDiffuse,
+	}
+	material.SetName("SAND_MDF")
+	list := &fragment.MaterialList{MaterialReferences: []uint32{w.Add(material)}}
+	mesh := &fragment.Mesh{
+		MaterialReference:    w.Add(list),
+		Center:               math32.Vector3{X: 10, Y: 20, Z: 30},
+		MaxDistance:          20,
+		MaxPosition:          math32.Vector3{X: 10, Y: 10, Z: 10},
+		Verticies:            []math32.Vector3{{}, {X: 10}, {Y: 10}, {Z: 10}},
+		TextureUVCoordinates: []math32.Vector2{{}, {X: 1}, {Y: 1}, {X: 1, Y: 1}},
+		Normals:              []math32.Vector3{{Z: 1}, {Z: 1}, {Z: 1}, {Z: 1}},
+		Indices: []*fragment.Polygon{
+			{IsSolid: true, Vertex1: 0, Vertex2: 1, Vertex3: 2},
+			{IsSolid: true, Vertex1: 0, Vertex2: 2, Vertex3: 3},
+		},
+		RenderGroups: []*fragment.MeshRenderGroup{{PolygonCount: 1}, {PolygonCount: 1}},
+	}
+	mesh.SetName("ZONE_DMSPRITEDEF")
+	w.Add(mesh)
+	return encodeZone(t, w)
+}
+
+// encodeZone encodes w to a file and decodes it back, so names and references resolve as they do for a loaded zone
+func encodeZone(t *testing.T, w *wld.Wld) *wld.Wld {
+	f, err := os.Create(filepath.Join(t.TempDir(), "zone.wld"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer f.Close()
+	err = w.Encode(f)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	decoded, err := wld.Decode(f)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return decoded
+}
+
+func TestFromWld(t *testing.T) {
+	g, err := FromWld(testZone(t))
+	if err != nil {
+		t.Fatalf("from wld: %v", err)
+	}
+	doc := g.Document
+
+	if len(doc.Nodes) != 1 || doc.Nodes[0].Name != "ZONE_DMSPRITEDEF" {
+		t.Fatalf("nodes %+v", doc.Nodes)
+	}
+	if doc.Nodes[0].Translation != [3]float32{10, 30, 20} {
+		t.Fatalf("translation %v", doc.Nodes[0].Translation)
+	}
+	if len(doc.Meshes) != 1 || len(doc.Meshes[0].Primitives) != 2 {
+		t.Fatalf("wanted 1 mesh of 2 primitives, got %d meshes", len(doc.Meshes))
+	}
+	for i, primitive := range doc.Meshes[0].Primitives {
+		if primitive.Material == nil || *primitive.Material != 0 {
+			t.Fatalf("primitive %d material %v", i, primitive.Material)
+		}
+		if doc.Accessors[*primitive.Indices].Count != 3 {
+			t.Fatalf("primitive %d indices %d", i, doc.Accessors[*primitive.Indices].Count)
+		}
+		for _, attribute := range []string{gltf.POSITION, gltf.NORMAL, gltf.TEXCOORD_0} {
+			index, ok := primitive.Attributes[attribute]
+			if !ok || doc.Accessors[index].Count != 4 {
+				t.Fatalf("primitive %d %s missing or short", i, attribute)
+			}
+		}
+		if _, ok := primitive.Attributes[gltf.COLOR_0]; ok {
+			t.Fatalf("primitive %d has colors the mesh does not", i)
+		}
+	}
+	if len(doc.Materials) != 1 || doc.Materials[0].Name != "SAND_MDF" {
+		t.Fatalf("materials %+v", doc.Materials)
+	}
+	texture := doc.Materials[0].PBRMetallicRoughness.BaseColorTexture
+	if texture == nil || len(doc.Images) != 1 || doc.Images[0].URI != "sand.bmp" {
+		t.Fatalf("texture %v images %d", texture, len(doc.Images))
+	}
+
+	path := filepath.Join(t.TempDir(), "zone.gltf")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	err = Save(f, g)
+	f.Close()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	g, err = LoadFile(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(g.Document.Nodes) != 1 || len(g.Document.Buffers) != 1 || len(g.Document.Buffers[0].Data) == 0 {
+		t.Fatalf("reloaded %d nodes %d buffers", len(g.Document.Nodes), len(g.Document.Buffers))
+	}
+}
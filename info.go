@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runInfo(args []string) error {
+	fs := newFlagSet("info", "[archives...]")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	paths, err := archivePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err = info(path)
+		if err != nil {
+			return fmt.Errorf("info %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func info(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	pr, err := pfs.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+
+	totalSize := 0
+	extensions := make(map[string]int)
+	for _, entry := range pr.Entries() {
+		totalSize += int(entry.Size)
+		extensions[strings.ToLower(filepath.Ext(entry.Name))]++
+	}
+
+	fmt.Printf("%s:\n", path)
+	fmt.Printf("  archive size: %d bytes\n", fi.Size())
+	fmt.Printf("  version: 0x%x\n", pr.Version)
+	fmt.Printf("  entries: %d\n", len(pr.Entries()))
+	fmt.Printf("  inflated size: %d bytes\n", totalSize)
+	if totalSize > 0 {
+		fmt.Printf("  ratio: %0.2f%%\n", float64(fi.Size())/float64(totalSize)*100)
+	}
+
+	exts := []string{}
+	for ext := range extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		name := ext
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("  %s: %d\n", name, extensions[ext])
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runList(args []string) error {
+	fs := newFlagSet("list", "[archives...]")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	paths, err := archivePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err = list(path)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func list(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pr, err := pfs.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+	fmt.Printf("%s:\n", path)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "size\tcrc\t name\n")
+	for _, entry := range pr.Entries() {
+		fmt.Fprintf(w, "%d\t0x%08x\t %s\n", entry.Size, entry.CRC, entry.Name)
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
@@ -1,69 +1,150 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/xackery/eqzxc/pfs"
 )
 
+// command is a subcommand of eqzxc
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	{name: "extract", usage: "extract archives into _<name>.<ext>/ directories", run: runExtract},
+	{name: "pack", usage: "pack an extracted _<name>.<ext>/ directory into an archive", run: runPack},
+	{name: "add", usage: "add or replace files in an archive in place", run: runAdd},
+	{name: "rm", usage: "remove entries from an archive in place", run: runRm},
+	{name: "list", usage: "list the entries of archives", run: runList},
+	{name: "info", usage: "summarize archives", run: runInfo},
+	{name: "convert", usage: "convert archive contents to another format", run: runConvert},
+	{name: "verify", usage: "check archives for errors", run: runVerify},
+	{name: "diff", usage: "list what changed between two archives", run: runDiff},
+}
+
 func main() {
 	start := time.Now()
-	err := run()
-	fmt.Printf("finished in %0.2f seconds", time.Since(start).Seconds())
+	err := run(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	// status goes to stderr so output such as diff -json can be piped
+	fmt.Fprintf(os.Stderr, "finished in %0.2f seconds\n", time.Since(start).Seconds())
 	if err != nil {
-		fmt.Println("failed:", err)
+		fmt.Fprintln(os.Stderr, "failed:", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	dirs, err := os.ReadDir(".")
-	if err != nil {
-		return fmt.Errorf("readdir: %w", err)
+func run(args []string) error {
+	// with no arguments, behave like the original tool and extract every archive in the working directory
+	if len(args) == 0 {
+		return runExtract([]string{"."})
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return flag.ErrHelp
 	}
-	for _, entry := range dirs {
-		if entry.Type().IsDir() {
+	for _, cmd := range commands {
+		if cmd.name != name {
 			continue
 		}
-		err = extract(entry.Name())
+		err := cmd.run(args[1:])
+		if err == flag.ErrHelp {
+			return err
+		}
 		if err != nil {
-			return fmt.Errorf("extract %s: %w", entry.Name(), err)
+			return fmt.Errorf("%s: %w", name, err)
 		}
+		return nil
 	}
-	//pfs.Load
-	return nil
+	usage()
+	return fmt.Errorf("unknown command %s", name)
 }
 
-func extract(path string) error {
-	if filepath.Ext(path) != ".s3d" {
-		return nil
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: eqzxc <command> [flags] [paths...]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.usage)
 	}
-	f, err := os.Open(path)
-	if err != nil {
-		return err
+	fmt.Fprintf(os.Stderr, "\npaths may be files, directories or globs. run eqzxc <command> -h for command flags\n")
+}
+
+// newFlagSet returns a flag set for a command with a usage line
+func newFlagSet(name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: eqzxc %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
 	}
-	defer f.Close()
-	content, err := pfs.Decode(f)
-	if err != nil {
-		return fmt.Errorf("load: %w", err)
+	return fs
+}
+
+// isArchive returns true if path has a pfs archive extension
+func isArchive(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".s3d", ".eqg", ".pak", ".pfs":
+		return true
 	}
-	outpath := fmt.Sprintf("_%s/", path)
-	err = os.MkdirAll(outpath, 0755)
-	if err != nil {
-		return fmt.Errorf("mkdirall %s: %w", path, err)
+	return false
+}
+
+// archivePaths expands files, directories and globs into a list of archive paths
+func archivePaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no paths provided")
 	}
-	fmt.Println(outpath)
-	for _, entry := range content.Files {
-		fPath := fmt.Sprintf("%s%s", outpath, entry.Name)
-		fmt.Println(fPath)
-		err = ioutil.WriteFile(fPath, entry.Data, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("write %s: %w", fPath, err)
+	paths := []string{}
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("glob %s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s matched no files", arg)
+			}
+		}
+		for _, match := range matches {
+			fi, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				if !isArchive(match) && len(matches) > 1 {
+					continue
+				}
+				add(match)
+				continue
+			}
+			entries, err := os.ReadDir(match)
+			if err != nil {
+				return nil, fmt.Errorf("readdir %s: %w", match, err)
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !isArchive(entry.Name()) {
+					continue
+				}
+				add(filepath.Join(match, entry.Name()))
+			}
 		}
 	}
-	return nil
+	return paths, nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runPack(args []string) error {
+	fs := newFlagSet("pack", "<_name.ext directories...>")
+	out := fs.String("out", "", "archive to write (defaults to <name>.<ext> next to the _<name>.<ext>/ directory)")
+	jobs := fs.Int("j", 0, "chunks to deflate at once (defaults to every cpu)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no directories provided")
+	}
+	if *out != "" && fs.NArg() > 1 {
+		return fmt.Errorf("-out can only be used when packing a single directory")
+	}
+	for _, dir := range fs.Args() {
+		dst := *out
+		if dst == "" {
+			dst, err = packPath(dir)
+			if err != nil {
+				return err
+			}
+		}
+		err = pack(dir, dst, *jobs)
+		if err != nil {
+			return fmt.Errorf("pack %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// packPath returns the archive path for an extracted _<name>.<ext>/ directory
+func packPath(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	base := filepath.Base(dir)
+	if !strings.HasPrefix(base, "_") || !isArchive(base) {
+		return "", fmt.Errorf("%s is not named _<name>.<ext>, use -out to pick the archive path", dir)
+	}
+	return filepath.Join(filepath.Dir(dir), strings.TrimPrefix(base, "_")), nil
+}
+
+func pack(dir string, dst string, jobs int) error {
+	archive, err := pfs.FromDirectory(dir)
+	if err != nil {
+		return fmt.Errorf("from directory: %w", err)
+	}
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	err = archive.EncodeWithOptions(w, pfs.EncodeOptions{Concurrency: jobs})
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	fmt.Println(dst)
+	return nil
+}
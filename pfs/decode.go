@@ -1,193 +1,184 @@
 package pfs
 
 import (
-	"bytes"
-	"compress/zlib"
-	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
-	"sort"
+	"strings"
 )
 
-// Decode will load a pfs file
+// DefaultMaxInflatedSize is the most an archive may inflate to when DecodeOptions does not set a limit
+const DefaultMaxInflatedSize = 1 << 31
+
+// DecodeOptions changes how an archive is decoded
+type DecodeOptions struct {
+	// Concurrency is how many chunks are inflated at once, 0 uses every cpu
+	Concurrency int
+	// MaxInflatedSize is the most every entry may inflate to combined, guarding against zip bombs.
+	// 0 uses DefaultMaxInflatedSize, and less than 0 disables the limit
+	MaxInflatedSize int64
+}
+
+// Decode will load a pfs file, inflating every entry.
+// Use NewReader to only inflate entries as they are needed
 func Decode(r io.ReadSeeker) (*Pfs, error) {
+	return DecodeWithOptions(r, DecodeOptions{})
+}
+
+// DecodeWithOptions will load a pfs file, inflating every entry
+func DecodeWithOptions(r io.ReadSeeker, opts DecodeOptions) (*Pfs, error) {
 	pfs := &Pfs{}
-	err := parse(r, pfs)
+	err := parse(r, pfs, opts)
 	if err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
 	return pfs, nil
 }
 
-func parse(r io.ReadSeeker, pfs *Pfs) error {
-	var directoryIndex uint32
-	var magicNumber uint32
-	var versionNumber uint32
-
-	var value uint32
-	err := binary.Read(r, binary.LittleEndian, &directoryIndex)
+func parse(r io.ReadSeeker, pfs *Pfs, opts DecodeOptions) error {
+	pr, err := NewReader(r)
 	if err != nil {
-		return fmt.Errorf("read directory index: %w", err)
+		return err
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &magicNumber)
-	if err != nil {
-		return fmt.Errorf("read magic number: %w", err)
+	maxSize := opts.MaxInflatedSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxInflatedSize
 	}
-
-	if magicNumber != 0x20534650 {
-		return fmt.Errorf("invalid magic number, got 0x%x, want 0x20534650", magicNumber)
+	totalSize := int64(0)
+	for _, entry := range pr.Entries() {
+		totalSize += int64(entry.Size)
 	}
-
-	err = binary.Read(r, binary.LittleEndian, &versionNumber)
-	if err != nil {
-		return fmt.Errorf("read version number: %w", err)
+	if maxSize > 0 && totalSize > maxSize {
+		return fmt.Errorf("entries inflate to %d bytes, limit is %d: %w", totalSize, maxSize, ErrTooLarge)
 	}
 
-	_, err = r.Seek(int64(directoryIndex), io.SeekStart)
-	if err != nil {
-		return fmt.Errorf("seek directory index: %w", err)
-	}
-	var fileCount uint32
-	err = binary.Read(r, binary.LittleEndian, &fileCount)
-	if err != nil {
-		return fmt.Errorf("read file count: %w", err)
-	}
-	if fileCount == 0 {
-		return fmt.Errorf("empty file")
+	pfs.layout = &layout{
+		version:        pr.Version,
+		directoryOrder: pr.directoryOrder,
+		filenames:      pr.filenames,
 	}
 
-	filenames := []string{}
-
-	for i := 0; i < int(fileCount); i++ {
-		entry := &PfsEntry{}
-
-		err = binary.Read(r, binary.LittleEndian, &entry.CRC)
-		if err != nil {
-			return fmt.Errorf("read crc %d/%d: %w", i, fileCount, err)
+	// entries are sorted by offset, so each one's gap is what lies between it and the previous region
+	end := int64(12)
+	readGap := func(offset int64) ([]byte, error) {
+		if offset <= end {
+			return nil, nil
 		}
-
-		err = binary.Read(r, binary.LittleEndian, &entry.Offset)
+		return pr.readRange(end, offset-end)
+	}
+	filenameOffset := int64(pr.filenameEntry.Offset)
+	filenameDone := false
+	readFilenames := func() error {
+		gap, err := readGap(filenameOffset)
 		if err != nil {
-			return fmt.Errorf("read offset %d/%d: %w", i, fileCount, err)
+			return fmt.Errorf("read filename directory gap: %w", err)
 		}
-		debugInfo := fmt.Sprintf("%d/%d 0x%x", i, fileCount, entry.Offset)
-		// size is the uncompressed size of the file
-		var size uint32
-		err = binary.Read(r, binary.LittleEndian, &size)
+		chunks, next, err := pr.readRawChunks(pr.filenameEntry)
 		if err != nil {
-			return fmt.Errorf("read size %s: %w", debugInfo, err)
+			return fmt.Errorf("read filename directory: %w", err)
 		}
+		pfs.layout.filenameGap = gap
+		pfs.layout.filenameChunks = chunks
+		pfs.layout.filenameIndex = len(pfs.Files)
+		end = next
+		filenameDone = true
+		return nil
+	}
 
-		buf := bytes.NewBuffer(nil)
-
-		cachedOffset, err := r.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("seek cached offset %s: %w", debugInfo, err)
+	// chunks are read in order, then inflated concurrently
+	type job struct {
+		entry int
+		chunk *ChunkEntry
+		data  []byte
+	}
+	jobs := []*job{}
+	for i, entry := range pr.Entries() {
+		if !filenameDone && filenameOffset < int64(entry.Offset) {
+			err = readFilenames()
+			if err != nil {
+				return err
+			}
 		}
-		_, err = r.Seek(int64(entry.Offset), io.SeekStart)
+		gap, err := readGap(int64(entry.Offset))
 		if err != nil {
-			return fmt.Errorf("seek offset %s: %w", debugInfo, err)
+			return fmt.Errorf("read gap %s %d/%d 0x%x: %w", entry.Name, i, len(pr.Entries()), entry.Offset, err)
 		}
-
-		for uint32(buf.Len()) != size {
-			var deflatedLength uint32
-			var inflatedLength uint32
-			err = binary.Read(r, binary.LittleEndian, &deflatedLength)
-			if err != nil {
-				return fmt.Errorf("read deflated length %s: %w", debugInfo, err)
-			}
-
-			err = binary.Read(r, binary.LittleEndian, &inflatedLength)
-			if err != nil {
-				return fmt.Errorf("read inflated length %s: %w", debugInfo, err)
-			}
-
-			//if inflatedLength < deflatedLength {
-			//	return fmt.Errorf("inflated < deflated, offset misaligned? %d/%d", i, fileCount)
-			//}
-
-			compressedData := make([]byte, deflatedLength)
-			err = binary.Read(r, binary.LittleEndian, compressedData)
-			if err != nil {
-				return fmt.Errorf("read compressed data: %s: %w", debugInfo, err)
-			}
-
-			fr, err := zlib.NewReaderDict(bytes.NewReader(compressedData), nil)
-			if err != nil {
-				return fmt.Errorf("zlib new reader dict %s: %w", debugInfo, err)
-			}
-
-			inflatedWritten, err := io.Copy(buf, fr)
-			if err != nil {
-				return fmt.Errorf("copy: %s: %w", debugInfo, err)
-			}
-
-			if inflatedWritten != int64(inflatedLength) {
-				return fmt.Errorf("inflate mismatch %s: %w", debugInfo, err)
-			}
+		chunks, next, err := pr.readRawChunks(entry)
+		if err != nil {
+			return fmt.Errorf("read %s %d/%d 0x%x: %w", entry.Name, i, len(pr.Entries()), entry.Offset, err)
 		}
-		if buf.Len() < int(size) {
-			return fmt.Errorf("size mismatch %s: %w", debugInfo, err)
+		if next > end {
+			end = next
 		}
-		entry.Data = buf.Bytes()
-
-		if entry.CRC == 0x61580AC9 {
-			fr := bytes.NewReader(buf.Bytes())
-			var filenameCount uint32
-			err = binary.Read(fr, binary.LittleEndian, &filenameCount)
-			if err != nil {
-				return fmt.Errorf("filename count %s: %w", debugInfo, err)
-			}
-
-			for j := uint32(0); j < filenameCount; j++ {
-				err = binary.Read(fr, binary.LittleEndian, &value)
-				if err != nil {
-					return fmt.Errorf("filename length %s: %w", debugInfo, err)
-				}
-				filename, err := parseFixedString(fr, value)
-				if err != nil {
-					return fmt.Errorf("filename %s: %w", debugInfo, err)
-				}
-				filenames = append(filenames, filename)
-			}
-
-			_, err = r.Seek(cachedOffset, io.SeekStart)
-			if err != nil {
-				return fmt.Errorf("seek cached offset %s: %w", debugInfo, err)
-			}
-			continue
+		for _, chunk := range chunks {
+			jobs = append(jobs, &job{entry: i, chunk: chunk})
 		}
-		pfs.Files = append(pfs.Files, entry)
-		_, err = r.Seek(cachedOffset, io.SeekStart)
+		pfs.Files = append(pfs.Files, &PfsEntry{
+			Name:   entry.Name,
+			CRC:    entry.CRC,
+			Offset: entry.Offset,
+			original: &entryOriginal{
+				name:   entry.Name,
+				size:   int(entry.Size),
+				chunks: chunks,
+				gap:    gap,
+			},
+		})
+	}
+	if !filenameDone {
+		err = readFilenames()
 		if err != nil {
-			return fmt.Errorf("seek cached offset %s: %w", debugInfo, err)
+			return err
 		}
 	}
 
-	sort.Sort(ByOffset(pfs.Files))
+	err = forEach(len(jobs), opts.Concurrency, func(i int) error {
+		var err error
+		jobs[i].data, err = inflateChunk(jobs[i].chunk)
+		if err != nil {
+			entry := pr.Entries()[jobs[i].entry]
+			return fmt.Errorf("inflate %s %d/%d 0x%x: %w", entry.Name, jobs[i].entry, len(pr.Entries()), entry.Offset, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// jobs are in entry order, so each entry's chunks are contiguous
 	for i, entry := range pfs.Files {
-		if len(filenames) < i {
-			return fmt.Errorf("entry %d has no name", i)
+		entry.Data = make([]byte, 0, entry.original.size)
+		for len(jobs) > 0 && jobs[0].entry == i {
+			entry.Data = append(entry.Data, jobs[0].data...)
+			jobs = jobs[1:]
 		}
-		entry.Name = filenames[i]
+		entry.original.hash = crc32.ChecksumIEEE(entry.Data)
+	}
+
+	pfs.layout.directoryGap, err = readGap(int64(pr.directoryIndex))
+	if err != nil {
+		return fmt.Errorf("read directory gap: %w", err)
+	}
+	footerOffset := int64(pr.directoryIndex) + 4 + int64(len(pr.directoryOrder))*12
+	pfs.layout.footer, err = pr.readRange(footerOffset, -1)
+	if err != nil {
+		return fmt.Errorf("read footer: %w", err)
 	}
 	return nil
 }
 
-func parseFixedString(r io.ReadSeeker, size uint32) (string, error) {
-
+func parseFixedString(r io.Reader, size uint32) (string, error) {
 	in := make([]byte, size)
-	_, err := r.Read(in)
+	_, err := io.ReadFull(r, in)
 	if err != nil {
 		return "", fmt.Errorf("read: %w", err)
 	}
-	final := ""
+	final := strings.Builder{}
 	for _, char := range in {
 		if char == 0x0 {
 			continue
 		}
-		final += string(char)
+		final.WriteRune(rune(char))
 	}
-	return final, nil
+	return final.String(), nil
 }
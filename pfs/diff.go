@@ -0,0 +1,170 @@
+package pfs
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+
+	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// DiffResult is what changed between two archives
+type DiffResult struct {
+	Added    []*DiffEntry `json:"added"`
+	Removed  []*DiffEntry `json:"removed"`
+	Modified []*DiffEntry `json:"modified"`
+}
+
+// DiffEntry is an entry that differs between two archives
+type DiffEntry struct {
+	Name string `json:"name"`
+	// OldSize and OldHash are unset for added entries
+	OldSize int    `json:"oldSize"`
+	OldHash string `json:"oldHash,omitempty"`
+	// NewSize and NewHash are unset for removed entries
+	NewSize   int    `json:"newSize"`
+	NewHash   string `json:"newHash,omitempty"`
+	SizeDelta int    `json:"sizeDelta"`
+	// Fragments is set for modified .wld entries
+	Fragments *FragmentDiff `json:"fragments,omitempty"`
+}
+
+// FragmentDiff is what changed between two versions of a wld, by fragment reference counting from 1
+type FragmentDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+	// Error is set if either version could not be decoded
+	Error string `json:"error,omitempty"`
+}
+
+// Empty returns true if the archives hold the same entries
+func (d *DiffResult) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff compares the entries of a and b by name, ignoring case, and content
+func Diff(a *Pfs, b *Pfs) *DiffResult {
+	d := &DiffResult{
+		Added:    []*DiffEntry{},
+		Removed:  []*DiffEntry{},
+		Modified: []*DiffEntry{},
+	}
+	oldEntries := make(map[string]*PfsEntry)
+	for _, entry := range a.Files {
+		oldEntries[strings.ToLower(entry.Name)] = entry
+	}
+	newEntries := make(map[string]*PfsEntry)
+	for _, entry := range b.Files {
+		newEntries[strings.ToLower(entry.Name)] = entry
+	}
+
+	for name, entry := range oldEntries {
+		if _, ok := newEntries[name]; ok {
+			continue
+		}
+		d.Removed = append(d.Removed, &DiffEntry{
+			Name:      entry.Name,
+			OldSize:   len(entry.Data),
+			OldHash:   contentHash(entry.Data),
+			SizeDelta: -len(entry.Data),
+		})
+	}
+	for name, entry := range newEntries {
+		old, ok := oldEntries[name]
+		if !ok {
+			d.Added = append(d.Added, &DiffEntry{
+				Name:      entry.Name,
+				NewSize:   len(entry.Data),
+				NewHash:   contentHash(entry.Data),
+				SizeDelta: len(entry.Data),
+			})
+			continue
+		}
+		if bytes.Equal(old.Data, entry.Data) {
+			continue
+		}
+		de := &DiffEntry{
+			Name:      entry.Name,
+			OldSize:   len(old.Data),
+			OldHash:   contentHash(old.Data),
+			NewSize:   len(entry.Data),
+			NewHash:   contentHash(entry.Data),
+			SizeDelta: len(entry.Data) - len(old.Data),
+		}
+		if strings.HasSuffix(name, ".wld") {
+			de.Fragments = diffFragments(old.Data, entry.Data)
+		}
+		d.Modified = append(d.Modified, de)
+	}
+
+	for _, entries := range [][]*DiffEntry{d.Added, d.Removed, d.Modified} {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+	}
+	return d
+}
+
+// contentHash is the crc32 of data, as hex
+func contentHash(data []byte) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(data))
+}
+
+// diffFragments compares two wld files fragment by fragment
+func diffFragments(a []byte, b []byte) *FragmentDiff {
+	fd := &FragmentDiff{
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []string{},
+	}
+	oldWld, err := wld.Decode(bytes.NewReader(a))
+	if err != nil {
+		fd.Error = fmt.Sprintf("old: %v", err)
+		return fd
+	}
+	newWld, err := wld.Decode(bytes.NewReader(b))
+	if err != nil {
+		fd.Error = fmt.Sprintf("new: %v", err)
+		return fd
+	}
+
+	for i := 0; i < len(oldWld.Fragments) || i < len(newWld.Fragments); i++ {
+		if i >= len(newWld.Fragments) {
+			fd.Removed = append(fd.Removed, fmt.Sprintf("%d %s", i+1, oldWld.Fragments[i].FragmentType()))
+			continue
+		}
+		if i >= len(oldWld.Fragments) {
+			fd.Added = append(fd.Added, fmt.Sprintf("%d %s", i+1, newWld.Fragments[i].FragmentType()))
+			continue
+		}
+		oldData, err := fragmentBytes(oldWld.Fragments[i])
+		if err != nil {
+			fd.Error = fmt.Sprintf("old %d: %v", i+1, err)
+			return fd
+		}
+		newData, err := fragmentBytes(newWld.Fragments[i])
+		if err != nil {
+			fd.Error = fmt.Sprintf("new %d: %v", i+1, err)
+			return fd
+		}
+		if !bytes.Equal(oldData, newData) {
+			fd.Modified = append(fd.Modified, fmt.Sprintf("%d %s", i+1, newWld.Fragments[i].FragmentType()))
+		}
+	}
+	return fd
+}
+
+// fragmentBytes is the code, name and encoded payload of frag, so fragments compare by what they write
+func fragmentBytes(frag fragment.Fragment) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%d %s\x00", frag.FragmentCode(), frag.Name())
+	err := frag.Write(buf)
+	if err != nil {
+		return nil, fmt.Errorf("write %s: %w", frag.FragmentType(), err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package pfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FromDirectory builds a pfs from the files of an extracted archive directory, such as _arena.s3d/
+func FromDirectory(path string) (*Pfs, error) {
+	dirs, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("readdir: %w", err)
+	}
+
+	pfs := &Pfs{
+		ShortName: DirectoryShortName(path),
+	}
+	names := make(map[string]string)
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+		// the client looks entries up by lower case name
+		name := strings.ToLower(dir.Name())
+		if prev, ok := names[name]; ok {
+			return nil, fmt.Errorf("%s and %s have the same archive name %s", prev, dir.Name(), name)
+		}
+		names[name] = dir.Name()
+
+		data, err := ioutil.ReadFile(filepath.Join(path, dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", dir.Name(), err)
+		}
+		pfs.Files = append(pfs.Files, &PfsEntry{
+			Name: name,
+			Data: data,
+		})
+	}
+	if len(pfs.Files) == 0 {
+		return nil, fmt.Errorf("%s has no files", path)
+	}
+	sort.Slice(pfs.Files, func(i, j int) bool {
+		return pfs.Files[i].Name < pfs.Files[j].Name
+	})
+	return pfs, nil
+}
+
+// DirectoryShortName returns the archive name for an extracted directory, e.g. _arena.s3d/ returns arena
+func DirectoryShortName(path string) string {
+	name := filepath.Base(filepath.Clean(path))
+	name = strings.TrimPrefix(name, "_")
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -11,64 +11,64 @@ import (
 	"github.com/xackery/wd"
 )
 
+// EncodeOptions changes how an archive is encoded
+type EncodeOptions struct {
+	// Preserve keeps the entry order, chunk boundaries, compressed bytes, footer and datestamp
+	// of a decoded archive for every entry that was not modified, so an untouched archive
+	// encodes byte for byte identical to the original
+	Preserve bool
+	// Concurrency is how many chunks are deflated at once, 0 uses every cpu.
+	// The archive written is the same no matter the concurrency
+	Concurrency int
+}
+
+// Encode writes a pfs archive to w
 func (pfs *Pfs) Encode(w io.WriteSeeker) error {
+	return pfs.EncodeWithOptions(w, EncodeOptions{})
+}
+
+// EncodeWithOptions writes a pfs archive to w
+func (pfs *Pfs) EncodeWithOptions(w io.WriteSeeker, opts EncodeOptions) error {
+	if opts.Preserve && pfs.layout != nil {
+		return pfs.encodePreserved(w, opts)
+	}
 	var err error
 	directoryIndex := uint32(12)
 	magicNumber := int32(0x20534650)
 	versionNumber := int32(0x00020000)
 
+	datas := [][]byte{}
 	for _, entry := range pfs.Files {
-		entry.chunks, entry.chunksTotalSize, err = deflateChunks(entry.Data)
-		if err != nil {
-			return fmt.Errorf("deflatechunks %s: %w", entry.Name, err)
-		}
-		directoryIndex += 4 + 4
-
-		directoryIndex += uint32(len(entry.chunks))
-
+		datas = append(datas, entry.Data)
+	}
+	chunks, err := deflateAll(datas, opts.Concurrency)
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+	for i, entry := range pfs.Files {
+		entry.chunks = chunks[i]
+		entry.chunksTotalSize = deflatedSize(entry.chunks)
 		entry.CRC = crc.FilenameCRC32(entry.Name)
 	}
 	sort.Sort(ByCRC(pfs.Files))
 
-	directoryBuf := &bytes.Buffer{}
-
-	err = binary.Write(directoryBuf, binary.LittleEndian, uint32(len(pfs.Files)))
-	if err != nil {
-		return fmt.Errorf("write len pfs.Files: %w", err)
-	}
+	names := []string{}
 	for _, entry := range pfs.Files {
-		err = binary.Write(directoryBuf, binary.LittleEndian, uint32(len(entry.Name)+1))
-		if err != nil {
-			return fmt.Errorf("write len pfs.File %s: %w", entry.Name, err)
-		}
-		err = binary.Write(directoryBuf, binary.LittleEndian, []byte(entry.Name))
-		if err != nil {
-			return fmt.Errorf("write entry name %s: %w", entry.Name, err)
-		}
-		err = binary.Write(directoryBuf, binary.LittleEndian, []byte{0x00})
-		if err != nil {
-			return fmt.Errorf("write zero %s: %w", entry.Name, err)
-		}
+		names = append(names, entry.Name)
+	}
+	directoryBuf, err := filenameDirectory(names)
+	if err != nil {
+		return fmt.Errorf("filename directory: %w", err)
 	}
-	pfs.directoryChunks, pfs.directoryChunksTotalSize, err = deflateChunks(directoryBuf.Bytes())
+	pfs.directoryChunks, pfs.directoryChunksTotalSize, err = deflateChunks(directoryBuf)
 	if err != nil {
 		return fmt.Errorf("deflateChunks directoryBuf: %w", err)
 	}
 
 	for _, entry := range pfs.Files {
-		chunkSize := len(entry.chunks)
-		if chunkSize < 1 {
-			chunkSize = 1
-		}
-		directoryIndex += uint32(4+4)*uint32(chunkSize) + entry.chunksTotalSize
-	}
-
-	chunkSize := len(pfs.directoryChunks)
-	if chunkSize < 1 {
-		chunkSize = 1
+		directoryIndex += uint32(4+4)*uint32(len(entry.chunks)) + entry.chunksTotalSize
 	}
-
-	directoryIndex += uint32(4+4)*uint32(chunkSize) + uint32(pfs.directoryChunksTotalSize)
+	directoryIndex += uint32(4+4)*uint32(len(pfs.directoryChunks)) + pfs.directoryChunksTotalSize
 
 	////
 	err = wd.PrintWrite(w, binary.LittleEndian, directoryIndex, "directory index")
@@ -86,47 +86,22 @@ func (pfs *Pfs) Encode(w io.WriteSeeker) error {
 		return fmt.Errorf("write version number: %w", err)
 	}
 
-	var ptr int64
+	// pointers are tracked instead of asked of w so debug writers that do not seek still work
+	ptr := uint32(12)
 	for _, entry := range pfs.Files {
-		ptr, err = w.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("seek file pointer %s: %w", entry.Name, err)
-		}
-		entry.filePointer = uint32(ptr)
+		entry.filePointer = ptr
+		ptr += uint32(4+4)*uint32(len(entry.chunks)) + entry.chunksTotalSize
 
-		for i, chunk := range entry.chunks {
-			err = wd.PrintWrite(w, binary.LittleEndian, chunk.deflatedSize, "deflated size %d/%d", i, len(entry.chunks))
-			if err != nil {
-				return fmt.Errorf("write %s deflated size %d: %w", entry.Name, i, err)
-			}
-			err = wd.PrintWrite(w, binary.LittleEndian, chunk.inflatedSize, "inflated size %d/%d", i, len(entry.chunks))
-			if err != nil {
-				return fmt.Errorf("write %s inflated size %d: %w", entry.Name, i, err)
-			}
-			err = wd.PrintWrite(w, binary.LittleEndian, chunk.data, "chunk data %d/%d", i, len(entry.chunks))
-			if err != nil {
-				return fmt.Errorf("write %s deflated data %d: %w", entry.Name, i, err)
-			}
+		err = writeChunks(w, entry.chunks)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", entry.Name, err)
 		}
 	}
 
-	filePtr, err := w.Seek(0, io.SeekCurrent)
+	filePtr := ptr
+	err = writeChunks(w, pfs.directoryChunks)
 	if err != nil {
-		return fmt.Errorf("seek directory chunk index: %w", err)
-	}
-	for i, chunk := range pfs.directoryChunks {
-		err = binary.Write(w, binary.LittleEndian, chunk.deflatedSize)
-		if err != nil {
-			return fmt.Errorf("write directory chunk deflated size %d: %w", i, err)
-		}
-		err = binary.Write(w, binary.LittleEndian, chunk.inflatedSize)
-		if err != nil {
-			return fmt.Errorf("write directory chunk inflated size %d: %w", i, err)
-		}
-		err = binary.Write(w, binary.LittleEndian, chunk.data)
-		if err != nil {
-			return fmt.Errorf("write directory chunk deflated data %d: %w", i, err)
-		}
+		return fmt.Errorf("write directory chunks: %w", err)
 	}
 
 	err = binary.Write(w, binary.LittleEndian, uint32(len(pfs.Files)+1))
@@ -149,9 +124,9 @@ func (pfs *Pfs) Encode(w io.WriteSeeker) error {
 		}
 	}
 
-	err = binary.Write(w, binary.LittleEndian, uint32(0xFFFFFFFF))
+	err = binary.Write(w, binary.LittleEndian, uint32(filenameDirectoryCRC))
 	if err != nil {
-		return fmt.Errorf("write filename directory footer: %w", err)
+		return fmt.Errorf("write filename directory crc: %w", err)
 	}
 
 	err = binary.Write(w, binary.LittleEndian, filePtr)
@@ -159,13 +134,9 @@ func (pfs *Pfs) Encode(w io.WriteSeeker) error {
 		return fmt.Errorf("write directory filePtr: %w", err)
 	}
 
-	directoryTotalSize := uint32(0)
-	for _, entry := range pfs.Files {
-		directoryTotalSize += uint32(len(entry.Data))
-	}
-	err = binary.Write(w, binary.LittleEndian, directoryTotalSize)
+	err = binary.Write(w, binary.LittleEndian, uint32(len(directoryBuf)))
 	if err != nil {
-		return fmt.Errorf("write directoryTotalSize: %w", err)
+		return fmt.Errorf("write directory size: %w", err)
 	}
 
 	err = binary.Write(w, binary.LittleEndian, []byte("STEVE"))
@@ -179,3 +150,261 @@ func (pfs *Pfs) Encode(w io.WriteSeeker) error {
 	}
 	return nil
 }
+
+// filenameDirectory returns the contents of the filename directory entry for names
+func filenameDirectory(names []string) ([]byte, error) {
+	directoryBuf := &bytes.Buffer{}
+
+	err := binary.Write(directoryBuf, binary.LittleEndian, uint32(len(names)))
+	if err != nil {
+		return nil, fmt.Errorf("write len names: %w", err)
+	}
+	for _, name := range names {
+		err = binary.Write(directoryBuf, binary.LittleEndian, uint32(len(name)+1))
+		if err != nil {
+			return nil, fmt.Errorf("write len %s: %w", name, err)
+		}
+		err = binary.Write(directoryBuf, binary.LittleEndian, []byte(name))
+		if err != nil {
+			return nil, fmt.Errorf("write name %s: %w", name, err)
+		}
+		err = binary.Write(directoryBuf, binary.LittleEndian, []byte{0x00})
+		if err != nil {
+			return nil, fmt.Errorf("write zero %s: %w", name, err)
+		}
+	}
+	return directoryBuf.Bytes(), nil
+}
+
+// writeChunks writes the chunks of an entry
+func writeChunks(w io.Writer, chunks []*ChunkEntry) error {
+	for i, chunk := range chunks {
+		err := wd.PrintWrite(w, binary.LittleEndian, chunk.deflatedSize, "deflated size %d/%d", i, len(chunks))
+		if err != nil {
+			return fmt.Errorf("write deflated size %d: %w", i, err)
+		}
+		err = wd.PrintWrite(w, binary.LittleEndian, chunk.inflatedSize, "inflated size %d/%d", i, len(chunks))
+		if err != nil {
+			return fmt.Errorf("write inflated size %d: %w", i, err)
+		}
+		err = wd.PrintWrite(w, binary.LittleEndian, chunk.data, "chunk data %d/%d", i, len(chunks))
+		if err != nil {
+			return fmt.Errorf("write deflated data %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// chunksSize returns how many bytes chunks take up in an archive
+func chunksSize(chunks []*ChunkEntry) uint32 {
+	size := uint32(0)
+	for _, chunk := range chunks {
+		size += 4 + 4 + uint32(len(chunk.data))
+	}
+	return size
+}
+
+// directoryRecord is an entry of the archive directory
+type directoryRecord struct {
+	crc    uint32
+	offset uint32
+	size   uint32
+}
+
+// encodePreserved writes a decoded archive reusing its original layout for unmodified entries
+func (pfs *Pfs) encodePreserved(w io.WriteSeeker, opts EncodeOptions) error {
+	var err error
+	l := pfs.layout
+
+	// decoded entries keep their original order, new entries follow sorted by crc
+	files := make([]*PfsEntry, len(pfs.Files))
+	copy(files, pfs.Files)
+	modified := []*PfsEntry{}
+	datas := [][]byte{}
+	for _, entry := range files {
+		if entry.isModified() {
+			entry.CRC = crc.FilenameCRC32(entry.Name)
+			modified = append(modified, entry)
+			datas = append(datas, entry.Data)
+			continue
+		}
+		entry.chunks = entry.original.chunks
+	}
+	chunks, err := deflateAll(datas, opts.Concurrency)
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+	for i, entry := range modified {
+		entry.chunks = chunks[i]
+		entry.chunksTotalSize = deflatedSize(entry.chunks)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if (a.original == nil) != (b.original == nil) {
+			return a.original != nil
+		}
+		if a.original != nil {
+			return a.Offset < b.Offset
+		}
+		return a.CRC < b.CRC
+	})
+
+	names := []string{}
+	for _, entry := range files {
+		names = append(names, entry.Name)
+	}
+	filenameChunks := l.filenameChunks
+	if !equalStrings(names, l.filenames) {
+		directoryBuf, err := filenameDirectory(names)
+		if err != nil {
+			return fmt.Errorf("filename directory: %w", err)
+		}
+		filenameChunks, _, err = deflateChunks(directoryBuf)
+		if err != nil {
+			return fmt.Errorf("deflateChunks filename directory: %w", err)
+		}
+	}
+	filenameIndex := l.filenameIndex
+	if filenameIndex > len(files) {
+		filenameIndex = len(files)
+	}
+
+	// lay out every region before writing, the header needs the directory offset
+	ptr := uint32(12)
+	records := make(map[uint32][]*directoryRecord)
+	var filenameRecord *directoryRecord
+	filenameSize := uint32(0)
+	for _, chunk := range filenameChunks {
+		filenameSize += uint32(chunk.inflatedSize)
+	}
+	for i := 0; i <= len(files); i++ {
+		if i == filenameIndex {
+			ptr += uint32(len(l.filenameGap))
+			filenameRecord = &directoryRecord{crc: filenameDirectoryCRC, offset: ptr, size: filenameSize}
+			ptr += chunksSize(filenameChunks)
+		}
+		if i == len(files) {
+			break
+		}
+		entry := files[i]
+		if entry.original != nil {
+			ptr += uint32(len(entry.original.gap))
+		}
+		entry.filePointer = ptr
+		ptr += chunksSize(entry.chunks)
+		records[entry.CRC] = append(records[entry.CRC], &directoryRecord{crc: entry.CRC, offset: entry.filePointer, size: uint32(len(entry.Data))})
+	}
+	directoryIndex := ptr + uint32(len(l.directoryGap))
+
+	// records keep their original directory order, new records are placed by crc
+	directory := []*directoryRecord{}
+	for _, c := range l.directoryOrder {
+		if c == filenameDirectoryCRC {
+			directory = append(directory, filenameRecord)
+			continue
+		}
+		if len(records[c]) == 0 {
+			continue
+		}
+		directory = append(directory, records[c][0])
+		records[c] = records[c][1:]
+	}
+	added := []*directoryRecord{}
+	for _, recs := range records {
+		added = append(added, recs...)
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return added[i].crc < added[j].crc
+	})
+	for _, rec := range added {
+		index := len(directory)
+		if index > 0 && directory[index-1] == filenameRecord {
+			index--
+		}
+		for i, existing := range directory {
+			if existing != filenameRecord && existing.crc > rec.crc {
+				index = i
+				break
+			}
+		}
+		directory = append(directory, nil)
+		copy(directory[index+1:], directory[index:])
+		directory[index] = rec
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, directoryIndex, "directory index")
+	if err != nil {
+		return fmt.Errorf("write directory index: %w", err)
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, uint32(0x20534650), "magic number")
+	if err != nil {
+		return fmt.Errorf("write magic number: %w", err)
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, l.version, "version number")
+	if err != nil {
+		return fmt.Errorf("write version number: %w", err)
+	}
+
+	for i := 0; i <= len(files); i++ {
+		if i == filenameIndex {
+			err = wd.PrintWrite(w, binary.LittleEndian, l.filenameGap, "filename directory gap")
+			if err != nil {
+				return fmt.Errorf("write filename directory gap: %w", err)
+			}
+			err = writeChunks(w, filenameChunks)
+			if err != nil {
+				return fmt.Errorf("write filename directory: %w", err)
+			}
+		}
+		if i == len(files) {
+			break
+		}
+		entry := files[i]
+		if entry.original != nil {
+			err = wd.PrintWrite(w, binary.LittleEndian, entry.original.gap, "gap %s", entry.Name)
+			if err != nil {
+				return fmt.Errorf("write %s gap: %w", entry.Name, err)
+			}
+		}
+		err = writeChunks(w, entry.chunks)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", entry.Name, err)
+		}
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, l.directoryGap, "directory gap")
+	if err != nil {
+		return fmt.Errorf("write directory gap: %w", err)
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, uint32(len(directory)), "file count")
+	if err != nil {
+		return fmt.Errorf("write file count: %w", err)
+	}
+	for _, rec := range directory {
+		err = wd.PrintWrite(w, binary.LittleEndian, rec, "directory record 0x%x", rec.crc)
+		if err != nil {
+			return fmt.Errorf("write directory record 0x%x: %w", rec.crc, err)
+		}
+	}
+
+	err = wd.PrintWrite(w, binary.LittleEndian, l.footer, "footer")
+	if err != nil {
+		return fmt.Errorf("write footer: %w", err)
+	}
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package pfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xackery/eqzxc/crc"
+)
+
+// FS exposes a pfs archive as a read only fs.FS.
+// Names are looked up ignoring case, the same way the everquest client does.
+// The archive is indexed when NewFS is called, so files added to pfs afterwards are not visible
+type FS struct {
+	pfs   *Pfs
+	names map[string]*PfsEntry
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// NewFS returns an fs.FS of pfs
+func NewFS(pfs *Pfs) *FS {
+	f := &FS{
+		pfs:   pfs,
+		names: make(map[string]*PfsEntry),
+	}
+	for _, entry := range pfs.Files {
+		f.names[strings.ToLower(entry.Name)] = entry
+	}
+	return f
+}
+
+func (f *FS) entry(op string, name string) (*PfsEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := f.names[strings.ToLower(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// Open opens the named file, or the archive root when name is "."
+func (f *FS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return &dirFile{fs: f}, nil
+	}
+	entry, err := f.entry("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{entry: entry, Reader: bytes.NewReader(entry.Data)}, nil
+}
+
+// ReadDir lists the archive root, the only directory in an archive
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		if _, err := f.entry("readdir", name); err != nil {
+			return nil, err
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries := make([]fs.DirEntry, 0, len(f.pfs.Files))
+	for _, entry := range f.pfs.Files {
+		entries = append(entries, &fileInfo{entry: entry})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Stat returns a FileInfo of the named file
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return rootInfo{}, nil
+	}
+	entry, err := f.entry("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{entry: entry}, nil
+}
+
+// ReadFile returns a copy of the contents of the named file
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	entry, err := f.entry("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(entry.Data))
+	copy(data, entry.Data)
+	return data, nil
+}
+
+// FileInfo describes a file inside a pfs archive
+type FileInfo interface {
+	fs.FileInfo
+	// CRC is the crc of the file name as stored in the archive directory
+	CRC() uint32
+}
+
+// fileInfo is both the fs.FileInfo and fs.DirEntry of an archive entry
+type fileInfo struct {
+	entry *PfsEntry
+}
+
+func (fi *fileInfo) Name() string               { return fi.entry.Name }
+func (fi *fileInfo) Size() int64                { return int64(len(fi.entry.Data)) }
+func (fi *fileInfo) Mode() fs.FileMode          { return 0444 }
+func (fi *fileInfo) ModTime() time.Time         { return time.Time{} }
+func (fi *fileInfo) IsDir() bool                { return false }
+func (fi *fileInfo) Sys() interface{}           { return fi.entry }
+func (fi *fileInfo) Type() fs.FileMode          { return 0 }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+func (fi *fileInfo) CRC() uint32 {
+	if fi.entry.CRC != 0 {
+		return fi.entry.CRC
+	}
+	return crc.FilenameCRC32(fi.entry.Name)
+}
+
+// rootInfo describes the archive root directory
+type rootInfo struct{}
+
+func (rootInfo) Name() string       { return "." }
+func (rootInfo) Size() int64        { return 0 }
+func (rootInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (rootInfo) ModTime() time.Time { return time.Time{} }
+func (rootInfo) IsDir() bool        { return true }
+func (rootInfo) Sys() interface{}   { return nil }
+
+// file is an open archive entry
+type file struct {
+	*bytes.Reader
+	entry *PfsEntry
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return &fileInfo{entry: f.entry}, nil }
+func (f *file) Close() error               { return nil }
+
+// dirFile is the open archive root
+type dirFile struct {
+	fs      *FS
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return rootInfo{}, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		var err error
+		d.entries, err = d.fs.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+	}
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
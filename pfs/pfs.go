@@ -3,7 +3,26 @@ package pfs
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"fmt"
+	"hash/crc32"
+)
+
+// filenameDirectoryCRC is the crc of the entry holding every file name in an archive
+const filenameDirectoryCRC = 0x61580AC9
+
+// maxDeflateRatio is the most zlib can compress data, so no chunk can inflate to more than its deflated size times this
+const maxDeflateRatio = 1032
+
+var (
+	// ErrBadMagic is returned when data is not a pfs archive
+	ErrBadMagic = errors.New("invalid magic number")
+	// ErrTruncated is returned when an archive ends before a structure it describes
+	ErrTruncated = errors.New("truncated")
+	// ErrCorrupt is returned when sizes or counts stored in an archive are impossible
+	ErrCorrupt = errors.New("corrupt")
+	// ErrTooLarge is returned when an archive inflates past a decode limit
+	ErrTooLarge = errors.New("too large")
 )
 
 // Pfs is a compression/zip format for everquest
@@ -12,6 +31,25 @@ type Pfs struct {
 	Files                    []*PfsEntry
 	directoryChunks          []*ChunkEntry
 	directoryChunksTotalSize uint32
+	// layout is set by Decode and lets EncodeWithOptions reproduce the original archive
+	layout *layout
+}
+
+// layout records how a decoded archive was stored, beyond the contents of its files
+type layout struct {
+	version uint32
+	// directoryOrder is the crc of every directory record, in the order they were stored
+	directoryOrder []uint32
+	// filenames are the names of the filename directory entry, in the order they were stored
+	filenames      []string
+	filenameChunks []*ChunkEntry
+	filenameGap    []byte
+	// filenameIndex is how many file regions were stored before the filename directory
+	filenameIndex int
+	// directoryGap is any data between the last region and the directory
+	directoryGap []byte
+	// footer is everything after the directory, usually STEVE and a datestamp
+	footer []byte
 }
 
 type ByOffset []*PfsEntry
@@ -50,6 +88,29 @@ type PfsEntry struct {
 	chunks          []*ChunkEntry
 	chunksTotalSize uint32
 	filePointer     uint32
+	// original is set by Decode to detect if an entry was modified
+	original *entryOriginal
+}
+
+// entryOriginal is how an entry was stored in a decoded archive
+type entryOriginal struct {
+	name   string
+	size   int
+	hash   uint32
+	chunks []*ChunkEntry
+	// gap is any data between the previous region and this entry
+	gap []byte
+}
+
+// isModified returns true if an entry was not decoded, or changed since it was
+func (e *PfsEntry) isModified() bool {
+	if e.original == nil {
+		return true
+	}
+	if e.original.name != e.Name || e.original.size != len(e.Data) {
+		return true
+	}
+	return e.original.hash != crc32.ChecksumIEEE(e.Data)
 }
 
 type ChunkEntry struct {
@@ -58,40 +119,71 @@ type ChunkEntry struct {
 	data         []byte
 }
 
+// chunkSize is how many bytes are inflated into each chunk
+const chunkSize = 8192
+
 func deflateChunks(data []byte) ([]*ChunkEntry, uint32, error) {
+	chunks, err := deflateAll([][]byte{data}, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return chunks[0], deflatedSize(chunks[0]), nil
+}
 
-	chunks := []*ChunkEntry{}
-	dataSize := len(data)
-	chunksTotalSize := uint32(0)
-	i := 0
-	for i < dataSize {
-		ce := &ChunkEntry{}
-		blockSize := 8192
-		if dataSize < 8192 {
-			blockSize = dataSize
-		}
-		buf := &bytes.Buffer{}
-		rawData := data[i : i+blockSize]
-		if rawData == nil {
-			fmt.Println("test")
-		}
+// deflatedSize returns the compressed size of chunks, not counting their headers
+func deflatedSize(chunks []*ChunkEntry) uint32 {
+	size := uint32(0)
+	for _, chunk := range chunks {
+		size += uint32(chunk.deflatedSize)
+	}
+	return size
+}
 
-		zw := zlib.NewWriter(buf)
-		_, err := zw.Write(data[i : i+blockSize])
-		if err != nil {
-			return nil, 0, fmt.Errorf("write: %w", err)
-		}
-		err = zw.Flush()
-		if err != nil {
-			return nil, 0, fmt.Errorf("flush: %w", err)
+// deflateAll splits every buffer of data into chunks and deflates them on up to concurrency goroutines.
+// The chunks of data[i] are returned in chunks[i], identical to deflating them one at a time
+func deflateAll(data [][]byte, concurrency int) ([][]*ChunkEntry, error) {
+	type job struct {
+		data  []byte
+		chunk *ChunkEntry
+	}
+	jobs := []*job{}
+	chunks := make([][]*ChunkEntry, len(data))
+	for i, buf := range data {
+		chunks[i] = []*ChunkEntry{}
+		for j := 0; j < len(buf); j += chunkSize {
+			end := j + chunkSize
+			if end > len(buf) {
+				end = len(buf)
+			}
+			jb := &job{data: buf[j:end], chunk: &ChunkEntry{}}
+			jobs = append(jobs, jb)
+			chunks[i] = append(chunks[i], jb.chunk)
 		}
-		ce.data = buf.Bytes()
-		ce.inflatedSize = int32(blockSize)
-		ce.deflatedSize = int32(len(ce.data))
-		chunks = append(chunks, ce)
-		chunksTotalSize += uint32(ce.deflatedSize)
-		i += blockSize
-		dataSize = len(data) - i
 	}
-	return chunks, chunksTotalSize, nil
+
+	err := forEach(len(jobs), concurrency, func(i int) error {
+		return deflateChunk(jobs[i].data, jobs[i].chunk)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// deflateChunk compresses data into ce
+func deflateChunk(data []byte, ce *ChunkEntry) error {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	_, err := zw.Write(data)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	ce.data = buf.Bytes()
+	ce.inflatedSize = int32(len(data))
+	ce.deflatedSize = int32(len(ce.data))
+	return nil
 }
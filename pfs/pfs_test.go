@@ -2,14 +2,22 @@ package pfs
 
 import (
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/xackery/eqzxc/crc"
 	"github.com/xackery/eqzxc/wld"
+	"github.com/xackery/eqzxc/wld/fragment"
 	"github.com/xackery/wd"
 )
 
@@ -78,3 +86,678 @@ shortname = "%s"`, wld.ShortName)
 		}
 	}
 }
+
+func TestFromDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "_test.s3d")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatalf("mkdirall %s: %v", dir, err)
+	}
+	files := map[string][]byte{
+		"test.wld":    bytes.Repeat([]byte("wld data "), 4000),
+		"Palette.BMP": []byte("palette"),
+		"empty.txt":   {},
+	}
+	for name, data := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+		if err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	pfs, err := FromDirectory(dir)
+	if err != nil {
+		t.Fatalf("from directory: %v", err)
+	}
+	if pfs.ShortName != "test" {
+		t.Fatalf("shortname wanted test, got %s", pfs.ShortName)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.s3d")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer w.Close()
+	err = pfs.Encode(w)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	_, err = w.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	out, err := Decode(w)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Files) != len(files) {
+		t.Fatalf("wanted %d files, got %d", len(files), len(out.Files))
+	}
+	for name, data := range files {
+		found := false
+		for _, entry := range out.Files {
+			if entry.Name != strings.ToLower(name) {
+				continue
+			}
+			found = true
+			if !bytes.Equal(entry.Data, data) {
+				t.Fatalf("%s data mismatch", name)
+			}
+		}
+		if !found {
+			t.Fatalf("%s not found", name)
+		}
+	}
+}
+
+// encodeTest encodes files into an archive and returns its bytes
+func encodeTest(t *testing.T, files map[string][]byte) []byte {
+	pfs := &Pfs{}
+	for name, data := range files {
+		pfs.Files = append(pfs.Files, &PfsEntry{Name: name, Data: data})
+	}
+	path := filepath.Join(t.TempDir(), "test.s3d")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer w.Close()
+	err = pfs.Encode(w)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestReader(t *testing.T) {
+	big := make([]byte, 100000)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	data := encodeTest(t, map[string][]byte{
+		"big.wld":   big,
+		"small.bmp": []byte("small"),
+	})
+
+	pr, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	if len(pr.Entries()) != 2 {
+		t.Fatalf("wanted 2 entries, got %d", len(pr.Entries()))
+	}
+
+	small, err := pr.ReadFile("SMALL.bmp")
+	if err != nil {
+		t.Fatalf("readfile: %v", err)
+	}
+	if string(small) != "small" {
+		t.Fatalf("small wanted small, got %s", small)
+	}
+
+	r, err := pr.Open("big.wld")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("readall: %v", err)
+	}
+	if !bytes.Equal(out, big) {
+		t.Fatalf("big data mismatch")
+	}
+
+	_, err = pr.ReadFile("missing.wld")
+	if err == nil {
+		t.Fatalf("expected missing.wld to fail")
+	}
+}
+
+func TestFS(t *testing.T) {
+	pfs := &Pfs{Files: []*PfsEntry{
+		{Name: "arena.wld", Data: []byte("wld")},
+		{Name: "palette.bmp", Data: []byte("bmp")},
+	}}
+	fsys := NewFS(pfs)
+	err := fstest.TestFS(fsys, "arena.wld", "palette.bmp")
+	if err != nil {
+		t.Fatalf("testfs: %v", err)
+	}
+
+	data, err := fs.ReadFile(fsys, "ARENA.WLD")
+	if err != nil {
+		t.Fatalf("readfile: %v", err)
+	}
+	if string(data) != "wld" {
+		t.Fatalf("wanted wld, got %s", data)
+	}
+
+	fi, err := fs.Stat(fsys, "Palette.bmp")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	info, ok := fi.(FileInfo)
+	if !ok {
+		t.Fatalf("stat did not return a pfs FileInfo")
+	}
+	if info.CRC() != 4073721515 {
+		t.Fatalf("crc wanted 4073721515, got %d", info.CRC())
+	}
+	if info.Size() != 3 {
+		t.Fatalf("size wanted 3, got %d", info.Size())
+	}
+
+	matches, err := fs.Glob(fsys, "*.wld")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(matches) != 1 || matches[0] != "arena.wld" {
+		t.Fatalf("glob wanted [arena.wld], got %v", matches)
+	}
+}
+
+// craftTest builds an archive the way a different packer might: filename directory first,
+// 4KiB best compression chunks, padding between entries, an unsorted directory and a custom footer
+func craftTest(t *testing.T, names []string, files [][]byte) []byte {
+	deflate := func(data []byte) []byte {
+		out := &bytes.Buffer{}
+		for i := 0; i < len(data); i += 4096 {
+			end := i + 4096
+			if end > len(data) {
+				end = len(data)
+			}
+			buf := &bytes.Buffer{}
+			zw, err := zlib.NewWriterLevel(buf, zlib.BestCompression)
+			if err != nil {
+				t.Fatalf("zlib: %v", err)
+			}
+			zw.Write(data[i:end])
+			zw.Close()
+			binary.Write(out, binary.LittleEndian, uint32(buf.Len()))
+			binary.Write(out, binary.LittleEndian, uint32(end-i))
+			out.Write(buf.Bytes())
+		}
+		return out.Bytes()
+	}
+	nameBuf := &bytes.Buffer{}
+	binary.Write(nameBuf, binary.LittleEndian, uint32(len(names)))
+	for _, name := range names {
+		binary.Write(nameBuf, binary.LittleEndian, uint32(len(name)+1))
+		nameBuf.WriteString(name)
+		nameBuf.WriteByte(0)
+	}
+
+	body := &bytes.Buffer{}
+	type record struct{ crc, offset, size uint32 }
+	records := []record{{filenameDirectoryCRC, 12, uint32(nameBuf.Len())}}
+	body.Write(deflate(nameBuf.Bytes()))
+	for i, data := range files {
+		body.Write([]byte{0xAA, 0xBB, 0xCC})
+		records = append(records, record{crc.FilenameCRC32(names[i]), uint32(12 + body.Len()), uint32(len(data))})
+		body.Write(deflate(data))
+	}
+
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.LittleEndian, uint32(12+body.Len()))
+	binary.Write(out, binary.LittleEndian, uint32(0x20534650))
+	binary.Write(out, binary.LittleEndian, uint32(0x00020000))
+	out.Write(body.Bytes())
+	binary.Write(out, binary.LittleEndian, uint32(len(records)))
+	for i := len(records) - 1; i >= 0; i-- {
+		binary.Write(out, binary.LittleEndian, records[i])
+	}
+	out.WriteString("STEVE")
+	binary.Write(out, binary.LittleEndian, uint32(1234))
+	return out.Bytes()
+}
+
+// encodeBytes encodes pfs with opts and returns the archive bytes
+func encodeBytes(t *testing.T, pfs *Pfs, opts EncodeOptions) []byte {
+	path := filepath.Join(t.TempDir(), "out.s3d")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer w.Close()
+	err = pfs.EncodeWithOptions(w, opts)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestEncodePreserve(t *testing.T) {
+	big := make([]byte, 20000)
+	for i := range big {
+		big[i] = byte(i % 251)
+	}
+	in := craftTest(t, []string{"big.wld", "small.bmp", "other.txt"}, [][]byte{big, []byte("small"), []byte("other")})
+
+	pfs, err := Decode(bytes.NewReader(in))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	out := encodeBytes(t, pfs, EncodeOptions{Preserve: true})
+	if !bytes.Equal(in, out) {
+		t.Fatalf("preserved encode differs from original (%d bytes vs %d)", len(out), len(in))
+	}
+
+	// a modified entry is recompressed, the rest are copied as is
+	bigChunks := in[bytes.Index(in, []byte{0xAA, 0xBB, 0xCC})+3:]
+	bigChunks = bigChunks[:bytes.Index(bigChunks, []byte{0xAA, 0xBB, 0xCC})]
+	for _, entry := range pfs.Files {
+		if entry.Name == "small.bmp" {
+			entry.Data = []byte("modified")
+		}
+	}
+	pfs.Files = append(pfs.Files, &PfsEntry{Name: "new.txt", Data: []byte("new")})
+	out = encodeBytes(t, pfs, EncodeOptions{Preserve: true})
+	if !bytes.Contains(out, bigChunks) {
+		t.Fatalf("unmodified big.wld chunks were not preserved")
+	}
+	if !bytes.HasSuffix(out, in[len(in)-9:]) {
+		t.Fatalf("footer was not preserved")
+	}
+	result, err := Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode modified: %v", err)
+	}
+	want := map[string]string{"big.wld": string(big), "small.bmp": "modified", "other.txt": "other", "new.txt": "new"}
+	if len(result.Files) != len(want) {
+		t.Fatalf("wanted %d files, got %d", len(want), len(result.Files))
+	}
+	for _, entry := range result.Files {
+		if want[entry.Name] != string(entry.Data) {
+			t.Fatalf("%s data mismatch", entry.Name)
+		}
+	}
+}
+
+func TestEncodePreserveArena(t *testing.T) {
+	path := "test/arena.s3d"
+	in, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Skipf("%s not found", path)
+	}
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	pfs, err := Decode(bytes.NewReader(in))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	out := encodeBytes(t, pfs, EncodeOptions{Preserve: true})
+	if !bytes.Equal(in, out) {
+		t.Fatalf("preserved encode differs from %s (%d bytes vs %d)", path, len(out), len(in))
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	pfs := &Pfs{}
+	for i := 0; i < 20; i++ {
+		data := make([]byte, i*3000)
+		for j := range data {
+			data[j] = byte(j*i + j/97)
+		}
+		pfs.Files = append(pfs.Files, &PfsEntry{Name: fmt.Sprintf("file%02d.bin", i), Data: data})
+	}
+
+	serial := encodeBytes(t, pfs, EncodeOptions{Concurrency: 1})
+	for _, concurrency := range []int{0, 2, 8} {
+		out := encodeBytes(t, pfs, EncodeOptions{Concurrency: concurrency})
+		if !bytes.Equal(serial, out) {
+			t.Fatalf("concurrency %d encode differs from serial encode", concurrency)
+		}
+
+		result, err := DecodeWithOptions(bytes.NewReader(serial), DecodeOptions{Concurrency: concurrency})
+		if err != nil {
+			t.Fatalf("decode concurrency %d: %v", concurrency, err)
+		}
+		if len(result.Files) != len(pfs.Files) {
+			t.Fatalf("concurrency %d: wanted %d files, got %d", concurrency, len(pfs.Files), len(result.Files))
+		}
+		for _, entry := range result.Files {
+			var want *PfsEntry
+			for _, src := range pfs.Files {
+				if src.Name == entry.Name {
+					want = src
+				}
+			}
+			if want == nil || !bytes.Equal(want.Data, entry.Data) {
+				t.Fatalf("concurrency %d: %s data mismatch", concurrency, entry.Name)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pfs := &Pfs{Files: []*PfsEntry{
+		{Name: "a.txt", Data: bytes.Repeat([]byte("a"), 10000)},
+		{Name: "b.txt", Data: []byte("b")},
+	}}
+	good := encodeBytes(t, pfs, EncodeOptions{})
+	result, err := Verify(bytes.NewReader(good))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.OK() || result.Entries != 2 {
+		t.Fatalf("wanted 2 entries and no problems, got %d entries and %v", result.Entries, result.Problems)
+	}
+
+	directoryIndex := binary.LittleEndian.Uint32(good)
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		checks []Check
+	}{
+		{"magic", func(data []byte) []byte {
+			data[4] = 'X'
+			return data
+		}, []Check{CheckHeader}},
+		{"crc", func(data []byte) []byte {
+			data[directoryIndex+4] ^= 0xFF
+			return data
+		}, []Check{CheckCRC}},
+		{"footer", func(data []byte) []byte {
+			return data[:len(data)-4]
+		}, []Check{CheckFooter}},
+		{"chunk", func(data []byte) []byte {
+			data[16] ^= 0xFF
+			return data
+		}, []Check{CheckChunk, CheckRegion}},
+		{"directory", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[directoryIndex:], 1000)
+			return data
+		}, []Check{CheckDirectory}},
+		{"orphan", func(data []byte) []byte {
+			out := append([]byte{}, data[:directoryIndex]...)
+			out = append(out, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(out, directoryIndex+4)
+			return append(out, data[directoryIndex:]...)
+		}, []Check{CheckRegion}},
+	}
+	for _, test := range tests {
+		data := test.modify(append([]byte{}, good...))
+		result, err := Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: verify: %v", test.name, err)
+		}
+		found := make(map[Check]bool)
+		for _, problem := range result.Problems {
+			found[problem.Check] = true
+		}
+		for _, check := range test.checks {
+			if !found[check] {
+				t.Fatalf("%s: wanted a %s problem, got %v", test.name, check, result.Problems)
+			}
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	pfs := &Pfs{Files: []*PfsEntry{
+		{Name: "a.txt", Data: bytes.Repeat([]byte("a"), 20000)},
+		{Name: "b.txt", Data: []byte("b")},
+	}}
+	good := encodeBytes(t, pfs, EncodeOptions{})
+	directoryIndex := binary.LittleEndian.Uint32(good)
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		opts   DecodeOptions
+		want   error
+	}{
+		{"empty", func(data []byte) []byte { return nil }, DecodeOptions{}, ErrTruncated},
+		{"magic", func(data []byte) []byte {
+			data[4] = 'X'
+			return data
+		}, DecodeOptions{}, ErrBadMagic},
+		{"directory index", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data, 0xFFFFFFF0)
+			return data
+		}, DecodeOptions{}, ErrTruncated},
+		{"file count", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[directoryIndex:], 0x7FFFFFFF)
+			return data
+		}, DecodeOptions{}, ErrTruncated},
+		{"deflated size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[12:], 0x7FFFFFFF)
+			return data
+		}, DecodeOptions{}, ErrTruncated},
+		{"inflated size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[16:], 0x7FFFFFFF)
+			return data
+		}, DecodeOptions{}, ErrCorrupt},
+		{"entry size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[directoryIndex+12:], 0xFFFFFFFF)
+			return data
+		}, DecodeOptions{}, ErrCorrupt},
+		{"truncated", func(data []byte) []byte { return data[:directoryIndex-10] }, DecodeOptions{}, ErrTruncated},
+		{"limit", func(data []byte) []byte { return data }, DecodeOptions{MaxInflatedSize: 1000}, ErrTooLarge},
+	}
+	for _, test := range tests {
+		data := test.modify(append([]byte{}, good...))
+		_, err := DecodeWithOptions(bytes.NewReader(data), test.opts)
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%s: wanted %v, got %v", test.name, test.want, err)
+		}
+	}
+
+	for _, name := range []string{"../../.bashrc", "/etc/x", "a/b.txt", "a\\b.txt", ".."} {
+		data := encodeBytes(t, &Pfs{Files: []*PfsEntry{{Name: name, Data: []byte("a")}}}, EncodeOptions{})
+		_, err := Decode(bytes.NewReader(data))
+		if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("name %q: wanted %v, got %v", name, ErrCorrupt, err)
+		}
+	}
+	for _, name := range []string{"a..bmp", "..a", "a.."} {
+		data := encodeBytes(t, &Pfs{Files: []*PfsEntry{{Name: name, Data: []byte("a")}}}, EncodeOptions{})
+		_, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("name %q: %v", name, err)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	pfs := &Pfs{Files: []*PfsEntry{
+		{Name: "a.txt", Data: bytes.Repeat([]byte("abc"), 4000)},
+		{Name: "b.txt", Data: []byte("b")},
+	}}
+	buf := &bufferWriteSeeker{}
+	err := pfs.Encode(buf)
+	if err != nil {
+		f.Fatalf("encode: %v", err)
+	}
+	f.Add(buf.Bytes())
+	f.Add(buf.Bytes()[:buf.Len()/2])
+	traversal := &bufferWriteSeeker{}
+	err = (&Pfs{Files: []*PfsEntry{{Name: "../../.bashrc", Data: []byte("a")}}}).Encode(traversal)
+	if err != nil {
+		f.Fatalf("encode traversal: %v", err)
+	}
+	f.Add(traversal.Bytes())
+	f.Add([]byte("\x0c\x00\x00\x00PFS \x00\x00\x02\x00\x00\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pfs, err := DecodeWithOptions(bytes.NewReader(data), DecodeOptions{Concurrency: 1, MaxInflatedSize: 1 << 24})
+		if err != nil {
+			return
+		}
+		for _, entry := range pfs.Files {
+			if !isSafeName(entry.Name) {
+				t.Fatalf("decoded unsafe name %q", entry.Name)
+			}
+		}
+		_, err = Verify(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("verify decoded archive: %v", err)
+		}
+		err = pfs.EncodeWithOptions(&bufferWriteSeeker{}, EncodeOptions{Preserve: true, Concurrency: 1})
+		if err != nil {
+			t.Fatalf("encode decoded archive: %v", err)
+		}
+	})
+}
+
+// bufferWriteSeeker lets tests encode to memory, encoding only ever appends
+type bufferWriteSeeker struct {
+	bytes.Buffer
+}
+
+func (b *bufferWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	return int64(b.Len()), nil
+}
+
+func TestUpdate(t *testing.T) {
+	big := make([]byte, 30000)
+	for i := range big {
+		big[i] = byte(i * 13)
+	}
+	pfs := &Pfs{Files: []*PfsEntry{
+		{Name: "big.wld", Data: big},
+		{Name: "old.bmp", Data: []byte("old")},
+		{Name: "gone.txt", Data: []byte("gone")},
+		{Name: "name.txt", Data: []byte("name")},
+	}}
+	in := encodeBytes(t, pfs, EncodeOptions{})
+	path := filepath.Join(t.TempDir(), "update.s3d")
+	err := ioutil.WriteFile(path, in, 0644)
+	if err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	u, err := NewUpdate(f)
+	if err != nil {
+		t.Fatalf("new update: %v", err)
+	}
+	bigEntry, _ := u.pr.Entry("big.wld")
+	bigEnd, err := u.pr.chunksEnd(bigEntry)
+	if err != nil {
+		t.Fatalf("chunks end: %v", err)
+	}
+	bigChunks := append([]byte{}, in[bigEntry.Offset:bigEnd]...)
+
+	err = u.Put("OLD.bmp", []byte("replaced"))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = u.Put("new.txt", []byte("new"))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = u.Remove("gone.txt")
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	err = u.Rename("name.txt", "renamed.txt")
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err = u.Rename("big.wld", "new.txt"); err == nil {
+		t.Fatalf("rename onto an existing entry should fail")
+	}
+	err = u.Apply()
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	// a second update works from the first
+	err = u.Put("second.txt", []byte("second"))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = u.Apply()
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if !bytes.Equal(out[bigEntry.Offset:bigEnd], bigChunks) {
+		t.Fatalf("big.wld chunks moved or changed")
+	}
+	result, err := Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]string{"big.wld": string(big), "old.bmp": "replaced", "new.txt": "new", "renamed.txt": "name", "second.txt": "second"}
+	if len(result.Files) != len(want) {
+		t.Fatalf("wanted %d files, got %d", len(want), len(result.Files))
+	}
+	for _, entry := range result.Files {
+		if want[entry.Name] != string(entry.Data) {
+			t.Fatalf("%s data mismatch", entry.Name)
+		}
+		if entry.CRC != crc.FilenameCRC32(entry.Name) {
+			t.Fatalf("%s crc 0x%x is stale", entry.Name, entry.CRC)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	// materialLists builds a wld of 0x31 material list fragments, each referencing one material
+	materialLists := func(refs ...uint32) []byte {
+		w := &wld.Wld{IsOldWorld: true}
+		for _, ref := range refs {
+			w.Add(&fragment.MaterialList{MaterialReferences: []uint32{ref}})
+		}
+		buf := &bufferWriteSeeker{}
+		err := w.Encode(buf)
+		if err != nil {
+			t.Fatalf("encode wld: %v", err)
+		}
+		return buf.Bytes()
+	}
+	a := &Pfs{Files: []*PfsEntry{
+		{Name: "same.txt", Data: []byte("same")},
+		{Name: "gone.txt", Data: []byte("gone")},
+		{Name: "zone.wld", Data: materialLists(1, 2)},
+		{Name: "grow.bmp", Data: []byte("grow")},
+	}}
+	b := &Pfs{Files: []*PfsEntry{
+		{Name: "SAME.txt", Data: []byte("same")},
+		{Name: "new.txt", Data: []byte("new")},
+		{Name: "zone.wld", Data: materialLists(1, 3, 3)},
+		{Name: "grow.bmp", Data: []byte("grown")},
+	}}
+	d := Diff(a, b)
+	if len(d.Added) != 1 || d.Added[0].Name != "new.txt" || d.Added[0].SizeDelta != 3 {
+		t.Fatalf("added: %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "gone.txt" || d.Removed[0].SizeDelta != -4 {
+		t.Fatalf("removed: %+v", d.Removed)
+	}
+	if len(d.Modified) != 2 || d.Modified[0].Name != "grow.bmp" || d.Modified[1].Name != "zone.wld" {
+		t.Fatalf("modified: %+v", d.Modified)
+	}
+	if d.Modified[0].SizeDelta != 1 || d.Modified[0].OldHash == d.Modified[0].NewHash || d.Modified[0].Fragments != nil {
+		t.Fatalf("grow.bmp: %+v", d.Modified[0])
+	}
+	frags := d.Modified[1].Fragments
+	if frags == nil || frags.Error != "" {
+		t.Fatalf("zone.wld fragments: %+v", frags)
+	}
+	if len(frags.Added) != 1 || len(frags.Modified) != 1 || len(frags.Removed) != 0 || frags.Added[0] != "3 Material List" || frags.Modified[0] != "2 Material List" {
+		t.Fatalf("zone.wld fragments: %+v", frags)
+	}
+	if !Diff(a, a).Empty() {
+		t.Fatalf("archive differs from itself")
+	}
+}
@@ -0,0 +1,58 @@
+package pfs
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workers returns how many goroutines to use for a concurrency setting, where 0 or less means every cpu
+func workers(concurrency int) int {
+	if concurrency <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return concurrency
+}
+
+// forEach calls fn for 0 through n-1 on up to concurrency goroutines.
+// Callers store results by index so output does not depend on scheduling.
+// The error of the lowest failing index is returned
+func forEach(n int, concurrency int, fn func(i int) error) error {
+	concurrency = workers(concurrency)
+	if concurrency > n {
+		concurrency = n
+	}
+	if concurrency <= 1 {
+		for i := 0; i < n; i++ {
+			err := fn(i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
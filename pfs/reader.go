@@ -0,0 +1,433 @@
+package pfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reader provides random access to the entries of a pfs archive.
+// Only the directory is parsed up front, entries are inflated on demand
+type Reader struct {
+	mu             sync.Mutex
+	r              io.ReadSeeker
+	size           int64
+	Version        uint32
+	entries        []*ReaderEntry
+	names          map[string]*ReaderEntry
+	directoryIndex uint32
+	// directoryOrder is the crc of every directory record, in the order they are stored
+	directoryOrder []uint32
+	filenameEntry  *ReaderEntry
+	filenames      []string
+}
+
+// ReaderEntry is a file inside a pfs archive
+type ReaderEntry struct {
+	Name string
+	CRC  uint32
+	// Offset is where the first chunk of the entry is stored
+	Offset uint32
+	// Size is the inflated size of the entry
+	Size uint32
+}
+
+// NewReader parses the directory of a pfs archive
+func NewReader(r io.ReadSeeker) (*Reader, error) {
+	pr := &Reader{
+		r:     r,
+		names: make(map[string]*ReaderEntry),
+	}
+	err := pr.parse()
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (pr *Reader) parse() error {
+	r := pr.r
+	var err error
+	pr.size, err = r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("seek end: %w", err)
+	}
+	if pr.size < 12 {
+		return fmt.Errorf("header: %w: archive is %d bytes", ErrTruncated, pr.size)
+	}
+	header, err := pr.readRange(0, 12)
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	pr.directoryIndex = binary.LittleEndian.Uint32(header[0:])
+	magicNumber := binary.LittleEndian.Uint32(header[4:])
+	pr.Version = binary.LittleEndian.Uint32(header[8:])
+	if magicNumber != 0x20534650 {
+		return fmt.Errorf("%w: got 0x%x, want 0x20534650", ErrBadMagic, magicNumber)
+	}
+
+	if int64(pr.directoryIndex)+4 > pr.size {
+		return fmt.Errorf("directory index 0x%x: %w: archive is %d bytes", pr.directoryIndex, ErrTruncated, pr.size)
+	}
+	countBuf, err := pr.readRange(int64(pr.directoryIndex), 4)
+	if err != nil {
+		return fmt.Errorf("read file count: %w", err)
+	}
+	fileCount := binary.LittleEndian.Uint32(countBuf)
+	if fileCount == 0 {
+		return fmt.Errorf("empty file")
+	}
+	if int64(fileCount)*12 > pr.size-int64(pr.directoryIndex)-4 {
+		return fmt.Errorf("directory of %d records: %w", fileCount, ErrTruncated)
+	}
+	directoryBuf, err := pr.readRange(int64(pr.directoryIndex)+4, int64(fileCount)*12)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+
+	for i := 0; i < int(fileCount); i++ {
+		entry := &ReaderEntry{
+			CRC:    binary.LittleEndian.Uint32(directoryBuf[i*12:]),
+			Offset: binary.LittleEndian.Uint32(directoryBuf[i*12+4:]),
+			Size:   binary.LittleEndian.Uint32(directoryBuf[i*12+8:]),
+		}
+		if int64(entry.Offset) >= pr.size {
+			return fmt.Errorf("entry %d/%d offset 0x%x: %w", i, fileCount, entry.Offset, ErrTruncated)
+		}
+		if int64(entry.Size) > pr.size*maxDeflateRatio {
+			return fmt.Errorf("entry %d/%d 0x%x size %d: %w", i, fileCount, entry.Offset, entry.Size, ErrCorrupt)
+		}
+
+		pr.directoryOrder = append(pr.directoryOrder, entry.CRC)
+		if entry.CRC == filenameDirectoryCRC {
+			if pr.filenameEntry != nil {
+				return fmt.Errorf("entry %d/%d: %w: second filename directory", i, fileCount, ErrCorrupt)
+			}
+			pr.filenameEntry = entry
+			continue
+		}
+		pr.entries = append(pr.entries, entry)
+	}
+	if pr.filenameEntry == nil {
+		return fmt.Errorf("filename directory not found")
+	}
+
+	data, err := pr.inflate(pr.filenameEntry)
+	if err != nil {
+		return fmt.Errorf("inflate filename directory: %w", err)
+	}
+	pr.filenames, err = parseFilenames(data)
+	if err != nil {
+		return fmt.Errorf("parse filename directory: %w", err)
+	}
+
+	// filenames are stored in the order entry data is laid out
+	sort.Slice(pr.entries, func(i, j int) bool {
+		return pr.entries[i].Offset < pr.entries[j].Offset
+	})
+	for i, entry := range pr.entries {
+		if i >= len(pr.filenames) {
+			return fmt.Errorf("entry %d has no name: %w", i, ErrCorrupt)
+		}
+		entry.Name = pr.filenames[i]
+		pr.names[strings.ToLower(entry.Name)] = entry
+	}
+	return nil
+}
+
+func parseFilenames(data []byte) ([]string, error) {
+	fr := bytes.NewReader(data)
+	var filenameCount uint32
+	err := binary.Read(fr, binary.LittleEndian, &filenameCount)
+	if err != nil {
+		return nil, fmt.Errorf("filename count: %w", ErrTruncated)
+	}
+
+	if int64(filenameCount)*4 > int64(fr.Len()) {
+		return nil, fmt.Errorf("filename count %d: %w", filenameCount, ErrCorrupt)
+	}
+
+	filenames := []string{}
+	for j := uint32(0); j < filenameCount; j++ {
+		var value uint32
+		err = binary.Read(fr, binary.LittleEndian, &value)
+		if err != nil {
+			return nil, fmt.Errorf("filename length %d: %w", j, ErrTruncated)
+		}
+		if int64(value) > int64(fr.Len()) {
+			return nil, fmt.Errorf("filename %d length %d: %w", j, value, ErrTruncated)
+		}
+		filename, err := parseFixedString(fr, value)
+		if err != nil {
+			return nil, fmt.Errorf("filename %d: %w", j, err)
+		}
+		if !isSafeName(filename) {
+			return nil, fmt.Errorf("filename %d %q: %w", j, filename, ErrCorrupt)
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// isSafeName reports if name can be joined to a directory without leaving it.
+// Names are a single path element, so only ".." itself leaves the directory, dots elsewhere such as a..bmp are fine
+func isSafeName(name string) bool {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return false
+	}
+	return name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// Entries returns every file of the archive in the order their data is stored
+func (pr *Reader) Entries() []*ReaderEntry {
+	return pr.entries
+}
+
+// Entry returns the file named name, ignoring case
+func (pr *Reader) Entry(name string) (*ReaderEntry, bool) {
+	entry, ok := pr.names[strings.ToLower(name)]
+	return entry, ok
+}
+
+// ReadFile inflates the file named name
+func (pr *Reader) ReadFile(name string) ([]byte, error) {
+	entry, ok := pr.Entry(name)
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	data, err := pr.inflate(entry)
+	if err != nil {
+		return nil, fmt.Errorf("inflate %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Open returns a reader that inflates the file named name one chunk at a time
+func (pr *Reader) Open(name string) (io.Reader, error) {
+	entry, ok := pr.Entry(name)
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	return pr.OpenEntry(entry), nil
+}
+
+// OpenEntry returns a reader that inflates entry one chunk at a time
+func (pr *Reader) OpenEntry(entry *ReaderEntry) io.Reader {
+	return &entryReader{
+		pr:        pr,
+		offset:    int64(entry.Offset),
+		remaining: entry.Size,
+	}
+}
+
+// inflate returns the complete contents of entry.
+// Every chunk is read before allocating, so the size entry claims is backed by data in the archive
+func (pr *Reader) inflate(entry *ReaderEntry) ([]byte, error) {
+	chunks, _, err := pr.readRawChunks(entry)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, entry.Size)
+	for _, chunk := range chunks {
+		inflated, err := inflateChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, inflated...)
+	}
+	return data, nil
+}
+
+// readChunk inflates the chunk stored at offset, returning it and the offset of the next chunk
+func (pr *Reader) readChunk(offset int64) (*ChunkEntry, []byte, int64, error) {
+	ce, next, err := pr.readRawChunk(offset)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	data, err := inflateChunk(ce)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("0x%x: %w", offset, err)
+	}
+	return ce, data, next, nil
+}
+
+// readRawChunk reads the chunk stored at offset without inflating it, returning it and the offset of the next chunk
+func (pr *Reader) readRawChunk(offset int64) (*ChunkEntry, int64, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	r := pr.r
+
+	if offset+8 > pr.size {
+		return nil, 0, fmt.Errorf("chunk header 0x%x: %w", offset, ErrTruncated)
+	}
+	_, err := r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, 0, fmt.Errorf("seek offset 0x%x: %w", offset, err)
+	}
+	ce := &ChunkEntry{}
+	err = binary.Read(r, binary.LittleEndian, &ce.deflatedSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read deflated length 0x%x: %w", offset, err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &ce.inflatedSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read inflated length 0x%x: %w", offset, err)
+	}
+
+	if ce.deflatedSize <= 0 || ce.inflatedSize <= 0 {
+		return nil, 0, fmt.Errorf("chunk 0x%x sizes %d/%d: %w", offset, uint32(ce.deflatedSize), uint32(ce.inflatedSize), ErrCorrupt)
+	}
+	if offset+8+int64(ce.deflatedSize) > pr.size {
+		return nil, 0, fmt.Errorf("chunk 0x%x deflated size %d: %w", offset, ce.deflatedSize, ErrTruncated)
+	}
+	ce.data = make([]byte, ce.deflatedSize)
+	_, err = io.ReadFull(r, ce.data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read compressed data 0x%x: %w", offset, err)
+	}
+	return ce, offset + 8 + int64(len(ce.data)), nil
+}
+
+// readRawChunks reads the chunks of entry without inflating them, returning them and the offset just past them
+func (pr *Reader) readRawChunks(entry *ReaderEntry) ([]*ChunkEntry, int64, error) {
+	chunks := []*ChunkEntry{}
+	size := uint32(0)
+	offset := int64(entry.Offset)
+	for size < entry.Size {
+		chunk, next, err := pr.readRawChunk(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if uint32(chunk.inflatedSize) > entry.Size-size {
+			return nil, 0, fmt.Errorf("chunk at 0x%x inflates to %d bytes, entry has %d left: %w", offset, chunk.inflatedSize, entry.Size-size, ErrCorrupt)
+		}
+		size += uint32(chunk.inflatedSize)
+		chunks = append(chunks, chunk)
+		offset = next
+	}
+	return chunks, offset, nil
+}
+
+// chunksEnd returns the offset just past the chunks of entry, reading only their headers
+func (pr *Reader) chunksEnd(entry *ReaderEntry) (int64, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	offset := int64(entry.Offset)
+	size := uint32(0)
+	for size < entry.Size {
+		if offset+8 > pr.size {
+			return 0, fmt.Errorf("chunk header 0x%x: %w", offset, ErrTruncated)
+		}
+		_, err := pr.r.Seek(offset, io.SeekStart)
+		if err != nil {
+			return 0, fmt.Errorf("seek offset 0x%x: %w", offset, err)
+		}
+		header := make([]uint32, 2)
+		err = binary.Read(pr.r, binary.LittleEndian, header)
+		if err != nil {
+			return 0, fmt.Errorf("read chunk header 0x%x: %w", offset, err)
+		}
+		if header[0] == 0 || header[1] == 0 || header[1] > entry.Size-size {
+			return 0, fmt.Errorf("chunk 0x%x sizes %d/%d: %w", offset, header[0], header[1], ErrCorrupt)
+		}
+		offset += 8 + int64(header[0])
+		if offset > pr.size {
+			return 0, fmt.Errorf("chunk 0x%x deflated size %d: %w", offset, header[0], ErrTruncated)
+		}
+		size += header[1]
+	}
+	return offset, nil
+}
+
+// footer returns the STEVE footer and datestamp stored after the directory, or a new one if it is missing
+func (pr *Reader) footer() ([]byte, error) {
+	offset := int64(pr.directoryIndex) + 4 + int64(len(pr.directoryOrder))*12
+	if pr.size-offset == 9 {
+		data, err := pr.readRange(offset, 9)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(data, []byte("STEVE")) {
+			return data, nil
+		}
+	}
+	return append([]byte("STEVE"), 69, 0, 0, 0), nil
+}
+
+// inflateChunk returns the inflated contents of a chunk, never inflating more than the chunk claims
+func inflateChunk(ce *ChunkEntry) ([]byte, error) {
+	if ce.inflatedSize <= 0 || int64(ce.inflatedSize) > int64(len(ce.data))*maxDeflateRatio {
+		return nil, fmt.Errorf("%d bytes can not inflate to %d: %w", len(ce.data), uint32(ce.inflatedSize), ErrCorrupt)
+	}
+	fr, err := zlib.NewReader(bytes.NewReader(ce.data))
+	if err != nil {
+		return nil, fmt.Errorf("zlib new reader: %w: %v", ErrCorrupt, err)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, ce.inflatedSize))
+	_, err = io.Copy(buf, io.LimitReader(fr, int64(ce.inflatedSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("inflate: %w: %v", ErrCorrupt, err)
+	}
+	if buf.Len() != int(ce.inflatedSize) {
+		return nil, fmt.Errorf("inflate mismatch: wanted %d bytes, got %d: %w", ce.inflatedSize, buf.Len(), ErrCorrupt)
+	}
+	return buf.Bytes(), nil
+}
+
+// readRange returns size bytes of the archive starting at offset
+func (pr *Reader) readRange(offset int64, size int64) ([]byte, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if size < 0 {
+		size = pr.size - offset
+	}
+	if offset < 0 || size < 0 || offset+size > pr.size {
+		return nil, fmt.Errorf("range 0x%x+%d: %w", offset, size, ErrTruncated)
+	}
+	_, err := pr.r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("seek 0x%x: %w", offset, err)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(pr.r, data)
+	if err != nil {
+		return nil, fmt.Errorf("read 0x%x: %w", offset, err)
+	}
+	return data, nil
+}
+
+// entryReader streams the inflated contents of an entry
+type entryReader struct {
+	pr        *Reader
+	offset    int64
+	remaining uint32
+	chunk     []byte
+}
+
+func (er *entryReader) Read(p []byte) (int, error) {
+	for len(er.chunk) == 0 {
+		if er.remaining == 0 {
+			return 0, io.EOF
+		}
+		_, chunk, next, err := er.pr.readChunk(er.offset)
+		if err != nil {
+			return 0, err
+		}
+		if uint32(len(chunk)) > er.remaining {
+			return 0, fmt.Errorf("chunk at 0x%x inflates past entry size: %w", er.offset, ErrCorrupt)
+		}
+		er.chunk = chunk
+		er.offset = next
+		er.remaining -= uint32(len(chunk))
+	}
+	n := copy(p, er.chunk)
+	er.chunk = er.chunk[n:]
+	return n, nil
+}
@@ -0,0 +1,239 @@
+package pfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/xackery/eqzxc/crc"
+)
+
+// Update adds, replaces, renames and removes entries of an archive in place.
+// Compressed chunks of entries left alone are never inflated or moved, only new data,
+// the filename directory and the directory are written past the last kept entry.
+// If Apply fails part way the archive is left corrupt, so keep a copy of anything irreplaceable
+type Update struct {
+	f       io.ReadWriteSeeker
+	pr      *Reader
+	entries []*updateEntry
+	// Concurrency is how many chunks of new data are deflated at once, 0 uses every cpu
+	Concurrency int
+}
+
+// updateEntry is an entry of an archive being updated
+type updateEntry struct {
+	name string
+	// source is where the entry is stored in the archive, nil if the entry has new data
+	source *ReaderEntry
+	data   []byte
+}
+
+// truncater is implemented by files that can shrink, such as *os.File
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// NewUpdate reads the directory of the archive f so it can be updated
+func NewUpdate(f io.ReadWriteSeeker) (*Update, error) {
+	pr, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	u := &Update{f: f, pr: pr}
+	for _, entry := range pr.Entries() {
+		u.entries = append(u.entries, &updateEntry{name: entry.Name, source: entry})
+	}
+	return u, nil
+}
+
+func (u *Update) find(name string) (int, bool) {
+	for i, entry := range u.entries {
+		if strings.EqualFold(entry.name, name) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Put adds an entry named name, replacing any entry of the same name
+func (u *Update) Put(name string, data []byte) error {
+	if name == "" {
+		return fmt.Errorf("empty name")
+	}
+	name = strings.ToLower(name)
+	i, ok := u.find(name)
+	if ok {
+		u.entries = append(u.entries[:i], u.entries[i+1:]...)
+	}
+	u.entries = append(u.entries, &updateEntry{name: name, data: data})
+	return nil
+}
+
+// Rename changes the name of an entry without touching its data
+func (u *Update) Rename(oldName string, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("empty name")
+	}
+	i, ok := u.find(oldName)
+	if !ok {
+		return fmt.Errorf("%s not found", oldName)
+	}
+	j, ok := u.find(newName)
+	if ok && i != j {
+		return fmt.Errorf("%s already exists", newName)
+	}
+	u.entries[i].name = strings.ToLower(newName)
+	return nil
+}
+
+// Remove deletes the entry named name
+func (u *Update) Remove(name string) error {
+	i, ok := u.find(name)
+	if !ok {
+		return fmt.Errorf("%s not found", name)
+	}
+	u.entries = append(u.entries[:i], u.entries[i+1:]...)
+	return nil
+}
+
+// Names returns the name of every entry the archive will have once applied
+func (u *Update) Names() []string {
+	names := []string{}
+	for _, entry := range u.entries {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// Apply writes the changes to the archive
+func (u *Update) Apply() error {
+	if len(u.entries) == 0 {
+		return fmt.Errorf("archive would be empty")
+	}
+
+	// kept entries stay where they are, so new data starts past the last of them
+	tail := int64(12)
+	kept := []*updateEntry{}
+	added := []*updateEntry{}
+	for _, entry := range u.entries {
+		if entry.source == nil {
+			added = append(added, entry)
+			continue
+		}
+		kept = append(kept, entry)
+		end, err := u.pr.chunksEnd(entry.source)
+		if err != nil {
+			return fmt.Errorf("scan %s: %w", entry.name, err)
+		}
+		if end > tail {
+			tail = end
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].source.Offset < kept[j].source.Offset
+	})
+
+	datas := [][]byte{}
+	for _, entry := range added {
+		datas = append(datas, entry.data)
+	}
+	chunks, err := deflateAll(datas, u.Concurrency)
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+
+	// names are stored in the order entry data is laid out
+	records := []*directoryRecord{}
+	names := []string{}
+	for _, entry := range kept {
+		records = append(records, &directoryRecord{crc: crc.FilenameCRC32(entry.name), offset: entry.source.Offset, size: entry.source.Size})
+		names = append(names, entry.name)
+	}
+
+	footer, err := u.pr.footer()
+	if err != nil {
+		return fmt.Errorf("read footer: %w", err)
+	}
+
+	_, err = u.f.Seek(tail, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek 0x%x: %w", tail, err)
+	}
+	ptr := uint32(tail)
+	for i, entry := range added {
+		records = append(records, &directoryRecord{crc: crc.FilenameCRC32(entry.name), offset: ptr, size: uint32(len(entry.data))})
+		names = append(names, entry.name)
+		err = writeChunks(u.f, chunks[i])
+		if err != nil {
+			return fmt.Errorf("write %s: %w", entry.name, err)
+		}
+		ptr += chunksSize(chunks[i])
+	}
+
+	directoryBuf, err := filenameDirectory(names)
+	if err != nil {
+		return fmt.Errorf("filename directory: %w", err)
+	}
+	filenameChunks, _, err := deflateChunks(directoryBuf)
+	if err != nil {
+		return fmt.Errorf("deflate filename directory: %w", err)
+	}
+	err = writeChunks(u.f, filenameChunks)
+	if err != nil {
+		return fmt.Errorf("write filename directory: %w", err)
+	}
+	filenameRecord := &directoryRecord{crc: filenameDirectoryCRC, offset: ptr, size: uint32(len(directoryBuf))}
+	ptr += chunksSize(filenameChunks)
+	directoryIndex := ptr
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].crc < records[j].crc
+	})
+	records = append(records, filenameRecord)
+	buf := &bytes.Buffer{}
+	err = binary.Write(buf, binary.LittleEndian, uint32(len(records)))
+	if err != nil {
+		return fmt.Errorf("write file count: %w", err)
+	}
+	for _, rec := range records {
+		err = binary.Write(buf, binary.LittleEndian, []uint32{rec.crc, rec.offset, rec.size})
+		if err != nil {
+			return fmt.Errorf("write directory 0x%08x: %w", rec.crc, err)
+		}
+	}
+	buf.Write(footer)
+	_, err = u.f.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("write directory: %w", err)
+	}
+	if t, ok := u.f.(truncater); ok {
+		err = t.Truncate(int64(directoryIndex) + int64(buf.Len()))
+		if err != nil {
+			return fmt.Errorf("truncate: %w", err)
+		}
+	}
+
+	_, err = u.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek header: %w", err)
+	}
+	err = binary.Write(u.f, binary.LittleEndian, directoryIndex)
+	if err != nil {
+		return fmt.Errorf("write directory index: %w", err)
+	}
+
+	// later updates work from what was just written
+	pr, err := NewReader(u.f)
+	if err != nil {
+		return fmt.Errorf("reread: %w", err)
+	}
+	u.pr = pr
+	u.entries = nil
+	for _, entry := range pr.Entries() {
+		u.entries = append(u.entries, &updateEntry{name: entry.Name, source: entry})
+	}
+	return nil
+}
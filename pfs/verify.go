@@ -0,0 +1,290 @@
+package pfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/xackery/eqzxc/crc"
+)
+
+// Check is a kind of test Verify runs against an archive
+type Check string
+
+const (
+	CheckHeader            Check = "header"
+	CheckDirectory         Check = "directory"
+	CheckChunk             Check = "chunk"
+	CheckCRC               Check = "crc"
+	CheckFilenameDirectory Check = "filename directory"
+	CheckRegion            Check = "region"
+	CheckFooter            Check = "footer"
+)
+
+// Problem is an issue Verify found in an archive
+type Problem struct {
+	Check Check `json:"check"`
+	// Offset is where in the archive the problem is
+	Offset int64 `json:"offset"`
+	// Name is the entry the problem belongs to, if known
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) String() string {
+	if p.Name != "" {
+		return fmt.Sprintf("%s 0x%x %s: %s", p.Check, p.Offset, p.Name, p.Message)
+	}
+	return fmt.Sprintf("%s 0x%x: %s", p.Check, p.Offset, p.Message)
+}
+
+// VerifyResult is every problem found in an archive
+type VerifyResult struct {
+	Size     int64      `json:"size"`
+	Version  uint32     `json:"version"`
+	Entries  int        `json:"entries"`
+	Problems []*Problem `json:"problems"`
+}
+
+// OK returns true if no problems were found
+func (vr *VerifyResult) OK() bool {
+	return len(vr.Problems) == 0
+}
+
+func (vr *VerifyResult) add(check Check, offset int64, name string, format string, a ...interface{}) *Problem {
+	p := &Problem{Check: check, Offset: offset, Name: name, Message: fmt.Sprintf(format, a...)}
+	vr.Problems = append(vr.Problems, p)
+	return p
+}
+
+// region is a range of the archive claimed by a structure
+type region struct {
+	start int64
+	end   int64
+	name  string
+}
+
+// verifyRecord is a directory record and what Verify learned about it
+type verifyRecord struct {
+	directoryRecord
+	name string
+	end  int64
+	data []byte
+	// chunkProblem is set when the chunks could not be walked, and is named once the filename directory is read
+	chunkProblem *Problem
+}
+
+// Verify checks every structure of a pfs archive, collecting problems instead of stopping at the first.
+// An error is only returned if r could not be read
+func Verify(r io.ReadSeeker) (*VerifyResult, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seek end: %w", err)
+	}
+	vr := &VerifyResult{Size: size}
+	readAt := func(offset int64, size int64) ([]byte, error) {
+		_, err := r.Seek(offset, io.SeekStart)
+		if err != nil {
+			return nil, fmt.Errorf("seek 0x%x: %w", offset, err)
+		}
+		data := make([]byte, size)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return nil, fmt.Errorf("read 0x%x: %w", offset, err)
+		}
+		return data, nil
+	}
+
+	if size < 12 {
+		vr.add(CheckHeader, 0, "", "archive is %d bytes, too small for a header", size)
+		return vr, nil
+	}
+	header, err := readAt(0, 12)
+	if err != nil {
+		return nil, err
+	}
+	directoryIndex := int64(binary.LittleEndian.Uint32(header[0:]))
+	magicNumber := binary.LittleEndian.Uint32(header[4:])
+	vr.Version = binary.LittleEndian.Uint32(header[8:])
+	if magicNumber != 0x20534650 {
+		vr.add(CheckHeader, 4, "", "invalid magic number, got 0x%x, want 0x20534650", magicNumber)
+	}
+	if vr.Version != 0x00010000 && vr.Version != 0x00020000 {
+		vr.add(CheckHeader, 8, "", "unknown version 0x%x", vr.Version)
+	}
+
+	if directoryIndex < 12 || directoryIndex+4 > size {
+		vr.add(CheckDirectory, 0, "", "directory index 0x%x is outside of the archive", directoryIndex)
+		return vr, nil
+	}
+	countBuf, err := readAt(directoryIndex, 4)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(binary.LittleEndian.Uint32(countBuf))
+	if count == 0 {
+		vr.add(CheckDirectory, directoryIndex, "", "directory is empty")
+	}
+	if directoryIndex+4+count*12 > size {
+		fit := (size - directoryIndex - 4) / 12
+		vr.add(CheckDirectory, directoryIndex, "", "directory has %d records but only %d fit in the archive", count, fit)
+		count = fit
+	}
+	directoryEnd := directoryIndex + 4 + count*12
+	directoryBuf, err := readAt(directoryIndex+4, count*12)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*verifyRecord{}
+	var filenameRecord *verifyRecord
+	crcs := make(map[uint32]bool)
+	for i := int64(0); i < count; i++ {
+		rec := &verifyRecord{}
+		rec.crc = binary.LittleEndian.Uint32(directoryBuf[i*12:])
+		rec.offset = binary.LittleEndian.Uint32(directoryBuf[i*12+4:])
+		rec.size = binary.LittleEndian.Uint32(directoryBuf[i*12+8:])
+		if crcs[rec.crc] {
+			vr.add(CheckDirectory, directoryIndex+4+i*12, "", "duplicate crc 0x%08x", rec.crc)
+		}
+		crcs[rec.crc] = true
+		if rec.crc == filenameDirectoryCRC {
+			if filenameRecord != nil {
+				vr.add(CheckFilenameDirectory, directoryIndex+4+i*12, "", "more than one filename directory record")
+				continue
+			}
+			filenameRecord = rec
+			continue
+		}
+		records = append(records, rec)
+	}
+	vr.Entries = len(records)
+
+	regions := []region{{start: 0, end: 12, name: "header"}, {start: directoryIndex, end: directoryEnd, name: "directory"}}
+
+	// walk the chunks of every record, inflating them to check the sizes they claim
+	all := make([]*verifyRecord, len(records))
+	copy(all, records)
+	if filenameRecord != nil {
+		filenameRecord.name = "filename directory"
+		all = append(all, filenameRecord)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].offset < all[j].offset
+	})
+	for _, rec := range all {
+		offset := int64(rec.offset)
+		name := ""
+		if rec == filenameRecord {
+			name = rec.name
+		}
+		if offset < 12 || offset >= size {
+			rec.chunkProblem = vr.add(CheckChunk, offset, name, "entry 0x%08x starts outside of the archive", rec.crc)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		for uint32(buf.Len()) < rec.size {
+			if offset+8 > size {
+				rec.chunkProblem = vr.add(CheckChunk, offset, name, "chunk header of entry 0x%08x is past the end of the archive", rec.crc)
+				break
+			}
+			chunkHeader, err := readAt(offset, 8)
+			if err != nil {
+				return nil, err
+			}
+			ce := &ChunkEntry{
+				deflatedSize: int32(binary.LittleEndian.Uint32(chunkHeader[0:])),
+				inflatedSize: int32(binary.LittleEndian.Uint32(chunkHeader[4:])),
+			}
+			if ce.deflatedSize <= 0 || offset+8+int64(ce.deflatedSize) > size {
+				rec.chunkProblem = vr.add(CheckChunk, offset, name, "deflated size %d does not fit in the archive", uint32(ce.deflatedSize))
+				break
+			}
+			if ce.inflatedSize <= 0 || uint32(buf.Len())+uint32(ce.inflatedSize) > rec.size {
+				rec.chunkProblem = vr.add(CheckChunk, offset, name, "inflated size %d overflows entry size %d", uint32(ce.inflatedSize), rec.size)
+				break
+			}
+			ce.data, err = readAt(offset+8, int64(ce.deflatedSize))
+			if err != nil {
+				return nil, err
+			}
+			data, err := inflateChunk(ce)
+			if err != nil {
+				rec.chunkProblem = vr.add(CheckChunk, offset, name, "%v", err)
+				break
+			}
+			buf.Write(data)
+			offset += 8 + int64(ce.deflatedSize)
+		}
+		rec.end = offset
+		rec.data = buf.Bytes()
+		regions = append(regions, region{start: int64(rec.offset), end: rec.end, name: fmt.Sprintf("entry 0x%08x", rec.crc)})
+	}
+
+	// names are stored in the order entry data is laid out, and each must hash to its record's crc
+	if filenameRecord == nil {
+		vr.add(CheckFilenameDirectory, directoryIndex, "", "filename directory record 0x%08x not found", uint32(filenameDirectoryCRC))
+	} else if filenameRecord.chunkProblem == nil {
+		filenames, err := parseFilenames(filenameRecord.data)
+		if err != nil {
+			vr.add(CheckFilenameDirectory, int64(filenameRecord.offset), filenameRecord.name, "%v", err)
+		} else {
+			if len(filenames) != len(records) {
+				vr.add(CheckFilenameDirectory, int64(filenameRecord.offset), filenameRecord.name, "has %d names for %d entries", len(filenames), len(records))
+			}
+			byOffset := make([]*verifyRecord, len(records))
+			copy(byOffset, records)
+			sort.SliceStable(byOffset, func(i, j int) bool {
+				return byOffset[i].offset < byOffset[j].offset
+			})
+			for i, rec := range byOffset {
+				if i >= len(filenames) {
+					break
+				}
+				rec.name = filenames[i]
+				want := crc.FilenameCRC32(rec.name)
+				if rec.crc != want {
+					vr.add(CheckCRC, int64(rec.offset), rec.name, "stored crc 0x%08x, name hashes to 0x%08x", rec.crc, want)
+				}
+			}
+		}
+	}
+	for _, rec := range records {
+		if rec.chunkProblem != nil {
+			rec.chunkProblem.Name = rec.name
+		}
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].start < regions[j].start
+	})
+	end := int64(0)
+	endName := ""
+	for _, rg := range regions {
+		if rg.start < end {
+			vr.add(CheckRegion, rg.start, "", "%s overlaps %s by %d bytes", rg.name, endName, end-rg.start)
+		}
+		if rg.start > end && rg.start <= directoryIndex {
+			vr.add(CheckRegion, end, "", "%d orphaned bytes before %s", rg.start-end, rg.name)
+		}
+		if rg.end > end {
+			end = rg.end
+			endName = rg.name
+		}
+	}
+
+	if directoryEnd < size {
+		footer, err := readAt(directoryEnd, size-directoryEnd)
+		if err != nil {
+			return nil, err
+		}
+		if len(footer) != 9 || !bytes.Equal(footer[:5], []byte("STEVE")) {
+			vr.add(CheckFooter, directoryEnd, "", "expected STEVE and a datestamp, got %d unknown bytes", len(footer))
+		}
+	} else {
+		vr.add(CheckFooter, directoryEnd, "", "missing")
+	}
+	return vr, nil
+}
@@ -1,6 +1,8 @@
 package q3bsp
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -30,3 +32,16 @@ func TestLoad(t *testing.T) {
 		t.Fatalf("encode: %s", err.Error())
 	}
 }
+
+func FuzzDecode(f *testing.F) {
+	data, err := ioutil.ReadFile("test/box.bsp")
+	if err != nil {
+		f.Fatalf("read box.bsp: %v", err)
+	}
+	f.Add(data)
+	f.Add(data[:len(data)/2])
+	f.Add(data[:200])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 )
 
 func Decode(r io.ReadSeeker) (*BSP, error) {
@@ -16,7 +17,16 @@ func Decode(r io.ReadSeeker) (*BSP, error) {
 }
 
 func (b *BSP) read(r io.ReadSeeker) error {
-	err := binary.Read(r, binary.LittleEndian, &b.header)
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("seek end: %w", err)
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek start: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &b.header)
 	if err != nil {
 		return fmt.Errorf("read header: %w", err)
 	}
@@ -34,6 +44,11 @@ func (b *BSP) read(r io.ReadSeeker) error {
 	if err != nil {
 		return fmt.Errorf("read dirEntries: %w", err)
 	}
+	for i, e := range dirEntries[:dirEntryVisdata+1] {
+		if e.Offset < 0 || e.Size < 0 || int64(e.Offset)+int64(e.Size) > size {
+			return fmt.Errorf("dirEntry %d offset %d size %d is outside of the %d byte file", i, e.Offset, e.Size, size)
+		}
+	}
 
 	_, err = r.Seek(int64(dirEntries[dirEntryEntities].Offset), io.SeekStart)
 	if err != nil {
@@ -273,6 +288,9 @@ func (b *BSP) read(r io.ReadSeeker) error {
 			return fmt.Errorf("read visdata size %d: %w", i, err)
 		}
 
+		if v.VectorCount < 0 || v.VectorSize < 0 || int64(v.VectorCount)*int64(v.VectorSize) > int64(dirEntries[dirEntryVisdata].Size) {
+			return fmt.Errorf("visdata %d has %d vectors of %d bytes, more than the lump holds", i, v.VectorCount, v.VectorSize)
+		}
 		v.Vectors = make([]uint8, v.VectorCount*v.VectorSize)
 		for j := range v.Vectors {
 			err = binary.Read(r, binary.LittleEndian, &v.Vectors[j])
@@ -289,14 +307,13 @@ func (b *BSP) read(r io.ReadSeeker) error {
 
 func parseFixedString(r io.Reader, size uint32) (string, error) {
 	in := make([]byte, size)
-	_, err := r.Read(in)
+	_, err := io.ReadFull(r, in)
 	if err != nil {
 		return "", fmt.Errorf("read: %w", err)
 	}
-	final := ""
+	final := strings.Builder{}
 	for _, char := range in {
-		final += string(char)
+		final.WriteRune(rune(char))
 	}
-	fmt.Println(len(final))
-	return final, nil
+	return final.String(), nil
 }
@@ -34,8 +34,14 @@ func (m *Q3Map) parse(r io.ReadSeeker) error {
 			line = line[0:strings.Index(line, "//")]
 		}
 
+		if strings.HasPrefix(line, `"`) && lastEntity == nil {
+			return fmt.Errorf("line %d key outside of an entity", lineNumber)
+		}
 		if strings.HasPrefix(line, `"origin"`) {
-			origin := line[strings.Index(line, `"origin"`)+10 : len(line)-1]
+			origin, err := quotedValue(line, `"origin"`)
+			if err != nil {
+				return fmt.Errorf("line %d origin: %w", lineNumber, err)
+			}
 			coords := strings.Split(origin, " ")
 			if len(coords) != 3 {
 				return fmt.Errorf("line %d expected 3 coordinates, got %d on %s", lineNumber, len(coords), line)
@@ -59,20 +65,35 @@ func (m *Q3Map) parse(r io.ReadSeeker) error {
 			continue
 		}
 		if strings.HasPrefix(line, `"classname"`) {
-			lastEntity.ClassName = line[strings.Index(line, `"classname"`)+13 : len(line)-1]
+			lastEntity.ClassName, err = quotedValue(line, `"classname"`)
+			if err != nil {
+				return fmt.Errorf("line %d classname: %w", lineNumber, err)
+			}
 			continue
 		}
 		if strings.HasPrefix(line, `"light"`) {
-			lastEntity.Light = line[strings.Index(line, `"light"`)+9 : len(line)-1]
+			lastEntity.Light, err = quotedValue(line, `"light"`)
+			if err != nil {
+				return fmt.Errorf("line %d light: %w", lineNumber, err)
+			}
 			continue
 		}
 		if strings.HasPrefix(line, `"angle"`) {
-			lastEntity.Light = line[strings.Index(line, `"angle"`)+9 : len(line)-1]
+			lastEntity.Light, err = quotedValue(line, `"angle"`)
+			if err != nil {
+				return fmt.Errorf("line %d angle: %w", lineNumber, err)
+			}
 			continue
 		}
 		if strings.HasPrefix(line, "(") && lastAction == "brushDef" {
+			if lastBrush == nil {
+				return fmt.Errorf("line %d brush definition outside of a brush", lineNumber)
+			}
 			lastBrushDef = &BrushDef{}
 			defs := strings.Split(line, " ")
+			if len(defs) < 31 {
+				return fmt.Errorf("line %d expected 31 brush definition fields, got %d", lineNumber, len(defs))
+			}
 			var val float64
 			val, err = strconv.ParseFloat(defs[1], 32)
 			if err != nil {
@@ -193,6 +214,19 @@ func (m *Q3Map) parse(r io.ReadSeeker) error {
 			return fmt.Errorf("unhandled '%s' at %d", line, lineNumber)
 		}
 	}
+	err = buf.Err()
+	if err != nil {
+		return fmt.Errorf("line %d: %w", lineNumber, err)
+	}
 
 	return nil
 }
+
+// quotedValue returns the quoted value following key on line
+func quotedValue(line string, key string) (string, error) {
+	value := strings.TrimSpace(line[len(key):])
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", fmt.Errorf("value %s is not quoted", value)
+	}
+	return value[1 : len(value)-1], nil
+}
@@ -1,6 +1,8 @@
 package q3map
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -22,3 +24,16 @@ func TestDecode(t *testing.T) {
 	}
 
 }
+
+func FuzzDecode(f *testing.F) {
+	data, err := ioutil.ReadFile("test/clz.map")
+	if err != nil {
+		f.Fatalf("read clz.map: %v", err)
+	}
+	f.Add(data)
+	f.Add(data[:len(data)/2])
+	f.Add([]byte("{\n\"classname\" \"worldspawn\"\n}\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
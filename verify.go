@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xackery/eqzxc/pfs"
+)
+
+func runVerify(args []string) error {
+	fs := newFlagSet("verify", "[archives...]")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	paths, err := archivePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, path := range paths {
+		result, err := verify(path)
+		if err != nil {
+			fmt.Printf("%s: FAIL %v\n", path, err)
+			failed++
+			continue
+		}
+		if !result.OK() {
+			fmt.Printf("%s: FAIL %d problems\n", path, len(result.Problems))
+			for _, problem := range result.Problems {
+				fmt.Printf("  %s\n", problem)
+			}
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK %d entries\n", path, result.Entries)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d archives failed", failed, len(paths))
+	}
+	return nil
+}
+
+func verify(path string) (*pfs.VerifyResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pfs.Verify(f)
+}
@@ -0,0 +1,78 @@
+package wld
+
+import "github.com/xackery/eqzxc/wld/fragment"
+
+// Actor returns the actor definition an object instance places, nil if it is not in this wld.
+// Zone placements often point at actors in the zone's _obj archive, so look there too
+func (wld *Wld) Actor(obj *fragment.ObjectInstance) *fragment.Actor {
+	actor, ok := wld.FragmentByName(obj.ActorName).(*fragment.Actor)
+	if !ok {
+		return nil
+	}
+	return actor
+}
+
+// ActorSkeleton returns the skeleton an actor animates with, nil for static actors
+func (wld *Wld) ActorSkeleton(actor *fragment.Actor) *fragment.SkeletonHierarchy {
+	for _, ref := range actor.SpriteReferences {
+		skelRef, ok := wld.Fragment(ref).(*fragment.SkeletonReference)
+		if !ok {
+			continue
+		}
+		skel, ok := wld.Fragment(skelRef.Reference).(*fragment.SkeletonHierarchy)
+		if ok {
+			return skel
+		}
+	}
+	return nil
+}
+
+// ActorMeshes returns every mesh an actor draws, either directly or skinned to its skeleton
+func (wld *Wld) ActorMeshes(actor *fragment.Actor) []*fragment.Mesh {
+	meshRefs := []uint32{}
+	for _, ref := range actor.SpriteReferences {
+		if _, ok := wld.Fragment(ref).(*fragment.MeshReference); ok {
+			meshRefs = append(meshRefs, ref)
+		}
+	}
+	skel := wld.ActorSkeleton(actor)
+	if skel != nil {
+		meshRefs = append(meshRefs, skel.MeshReferences...)
+		for _, bone := range skel.Bones {
+			if bone.MeshReference != 0 {
+				meshRefs = append(meshRefs, bone.MeshReference)
+			}
+		}
+	}
+
+	meshes := []*fragment.Mesh{}
+	seen := make(map[*fragment.Mesh]bool)
+	for _, ref := range meshRefs {
+		meshRef, ok := wld.Fragment(ref).(*fragment.MeshReference)
+		if !ok {
+			continue
+		}
+		mesh, ok := wld.Fragment(meshRef.Reference).(*fragment.Mesh)
+		if !ok || seen[mesh] {
+			continue
+		}
+		seen[mesh] = true
+		meshes = append(meshes, mesh)
+	}
+	return meshes
+}
+
+// ActorBoundingRadius returns the skeleton's bounding radius, or the largest mesh's for static actors
+func (wld *Wld) ActorBoundingRadius(actor *fragment.Actor) float32 {
+	skel := wld.ActorSkeleton(actor)
+	if skel != nil && skel.BoundingRadius > 0 {
+		return skel.BoundingRadius
+	}
+	radius := float32(0)
+	for _, mesh := range wld.ActorMeshes(actor) {
+		if mesh.MaxDistance > radius {
+			radius = mesh.MaxDistance
+		}
+	}
+	return radius
+}
@@ -4,28 +4,48 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"strings"
 
 	"github.com/xackery/eqzxc/wld/fragment"
 )
 
+// DecodeOptions changes how a world file is decoded
+type DecodeOptions struct {
+	// Logf is told of what is worth knowing but is not an error, such as fragments of an unknown type.
+	// nil logs nothing
+	Logf func(format string, args ...interface{})
+}
+
 // Decode will load a world file
 func Decode(r io.ReadSeeker) (*Wld, error) {
+	return DecodeWithOptions(r, DecodeOptions{})
+}
+
+// DecodeWithOptions will load a world file
+func DecodeWithOptions(r io.ReadSeeker, opts DecodeOptions) (*Wld, error) {
 	wld := &Wld{}
-	err := parse(r, wld)
+	err := parse(r, wld, opts)
 	if err != nil {
 		return nil, fmt.Errorf("parse wld: %w", err)
 	}
 	return wld, nil
 }
 
-func parse(r io.ReadSeeker, wld *Wld) error {
+func parse(r io.ReadSeeker, wld *Wld, opts DecodeOptions) error {
 	if wld == nil {
 		return fmt.Errorf("wld nil")
 	}
 	var value uint32
 
-	err := binary.Read(r, binary.LittleEndian, &value)
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("seek end: %w", err)
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek start: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &value)
 	if err != nil {
 		return fmt.Errorf("read wld header: %w", err)
 	}
@@ -74,19 +94,21 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 		return fmt.Errorf("read after hash size offset: %w", err)
 	}
 
-	hashRaw, err := parseFixedString(r, hashSize)
+	if int64(hashSize) > size-28 {
+		return fmt.Errorf("hash size %d is larger than the %d byte file", hashSize, size)
+	}
+	hashRaw := make([]byte, hashSize)
+	_, err = io.ReadFull(r, hashRaw)
 	if err != nil {
 		return fmt.Errorf("read hash: %w", err)
 	}
+	wld.Hash = parseStringHash(fragment.DecodeString(hashRaw))
+	wld.hashSize = len(hashRaw)
 
-	hashSplit := strings.Split(hashRaw, "\x00")
-	wld.Hash = make(map[int]string)
-	offset := 0
-	for _, h := range hashSplit {
-		wld.Hash[offset] = h
-		offset += len(h) + 1
+	// every fragment is at least a size and index
+	if int64(wld.FragmentCount)*8 > size-28-int64(hashSize) {
+		return fmt.Errorf("fragment count %d does not fit in the %d byte file", wld.FragmentCount, size)
 	}
-
 	for i := 0; i < int(wld.FragmentCount); i++ {
 		var fragSize uint32
 		var fragIndex int32
@@ -104,10 +126,46 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 		if err != nil {
 			return fmt.Errorf("frag position seek %d/%d: %w", i, wld.FragmentCount, err)
 		}
+		if int64(fragSize) > size-fragPosition {
+			return fmt.Errorf("fragment %d/%d size %d passes end of file", i, wld.FragmentCount, fragSize)
+		}
+		// every fragment starts with a reference to its name
+		var nameRef int32
+		if fragSize >= 4 {
+			err = binary.Read(r, binary.LittleEndian, &nameRef)
+			if err != nil {
+				return fmt.Errorf("read name reference %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			_, err = r.Seek(fragPosition, io.SeekStart)
+			if err != nil {
+				return fmt.Errorf("seek fragment %d/%d: %w", i, wld.FragmentCount, err)
+			}
+		}
 		switch fragIndex {
+		case 0x03:
+			v, err := fragment.LoadBitmapName(r)
+			if err != nil {
+				return fmt.Errorf("parse bitmap name %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
+		case 0x04:
+			v, err := fragment.LoadBitmapInfo(r)
+			if err != nil {
+				return fmt.Errorf("parse bitmap info %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
+		case 0x05:
+			v, err := fragment.LoadBitmapInfoReference(r)
+			if err != nil {
+				return fmt.Errorf("parse bitmap info reference %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		case 0x10:
-			//TODO: skeleton hierarchy
-			return fmt.Errorf("skeleton hierarchy detected and not supported")
+			v, err := fragment.LoadSkeletonHierarchy(r)
+			if err != nil {
+				return fmt.Errorf("parse skeleton hierarchy %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		case 0x11:
 			t, err := fragment.LoadSkeletonReference(r)
 			if err != nil {
@@ -126,6 +184,12 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 				return fmt.Errorf("parse track reference %d/%d: %w", i, wld.FragmentCount, err)
 			}
 			wld.Fragments = append(wld.Fragments, t)
+		case 0x14:
+			v, err := fragment.LoadActor(r)
+			if err != nil {
+				return fmt.Errorf("parse actor %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		case 0x15:
 			t, err := fragment.LoadObjectInstance(r)
 			if err != nil {
@@ -144,6 +208,12 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 				return fmt.Errorf("parse light source reference %d/%d: %w", i, wld.FragmentCount, err)
 			}
 			wld.Fragments = append(wld.Fragments, l)
+		case 0x21:
+			v, err := fragment.LoadBspTree(r)
+			if err != nil {
+				return fmt.Errorf("parse bsp tree %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		case 0x22:
 			v, err := fragment.LoadBspRegion(r)
 			if err != nil {
@@ -168,6 +238,12 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 				return fmt.Errorf("parse light instance %d/%d: %w", i, wld.FragmentCount, err)
 			}
 			wld.Fragments = append(wld.Fragments, v)
+		case 0x29:
+			v, err := fragment.LoadRegionFlag(r)
+			if err != nil {
+				return fmt.Errorf("parse region flag %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		case 0x2C:
 			v, err := fragment.LoadLegacyMesh(r)
 			if err != nil {
@@ -210,9 +286,36 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 				return fmt.Errorf("parse particle cloud %d/%d: %w", i, wld.FragmentCount, err)
 			}
 			wld.Fragments = append(wld.Fragments, v)
+		case 0x36:
+			v, err := fragment.LoadMesh(r, !wld.IsOldWorld)
+			if err != nil {
+				return fmt.Errorf("parse mesh %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
 		default:
-			//return fmt.Errorf("unsupported fragment 0x%x %d/%d", fragIndex, i, wld.FragmentCount)
-			fmt.Printf("unsupported fragment 0x%x\n", fragIndex)
+			v, err := fragment.LoadUnknownFragment(r, fragIndex, fragSize)
+			if err != nil {
+				return fmt.Errorf("parse unknown fragment %d/%d: %w", i, wld.FragmentCount, err)
+			}
+			wld.Fragments = append(wld.Fragments, v)
+			if opts.Logf != nil {
+				opts.Logf("fragment %d/%d type 0x%x is unknown, kept as raw data", i, wld.FragmentCount, fragIndex)
+			}
+		}
+
+		frag := wld.Fragments[len(wld.Fragments)-1]
+		if n, ok := frag.(namer); ok {
+			n.SetName(wld.Name(nameRef))
+		}
+		switch v := frag.(type) {
+		case *fragment.ObjectInstance:
+			v.ActorName = wld.Name(v.ActorReference)
+		case *fragment.Actor:
+			v.CallbackName = wld.Name(v.CallbackReference)
+		case *fragment.SkeletonHierarchy:
+			for _, bone := range v.Bones {
+				bone.Name = wld.Name(bone.NameReference)
+			}
 		}
 
 		_, err = r.Seek(fragPosition+int64(fragSize), io.SeekStart)
@@ -222,19 +325,3 @@ func parse(r io.ReadSeeker, wld *Wld) error {
 	}
 	return nil
 }
-
-func parseFixedString(r io.ReadSeeker, size uint32) (string, error) {
-	in := make([]byte, size)
-	_, err := r.Read(in)
-	if err != nil {
-		return "", fmt.Errorf("read: %w", err)
-	}
-	final := ""
-	for _, char := range in {
-		if char == 0x0 {
-			continue
-		}
-		final += string(char)
-	}
-	return final, nil
-}
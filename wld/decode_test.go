@@ -1,9 +1,14 @@
 package wld
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"os"
 	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld/fragment"
 )
 
 func TestDecode(t *testing.T) {
@@ -20,3 +25,334 @@ func TestDecode(t *testing.T) {
 	}
 	fmt.Println(wld.ShortName)
 }
+
+// wldBytes returns an old world wld of a plain text hash and fragments made by fragBytes
+func wldBytes(bspRegionCount uint32, hash string, frags ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, []uint32{0x54503D02, 0x00015500, uint32(len(frags)), bspRegionCount, 0, uint32(len(hash)), 0})
+	buf.Write(fragment.DecodeString([]byte(hash)))
+	for _, frag := range frags {
+		buf.Write(frag)
+	}
+	return buf.Bytes()
+}
+
+// fragBytes returns a fragment of code, its payload each of values written little endian
+func fragBytes(code int32, values ...interface{}) []byte {
+	payload := &bytes.Buffer{}
+	for _, value := range values {
+		binary.Write(payload, binary.LittleEndian, value)
+	}
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, []int32{int32(payload.Len()), code})
+	buf.Write(payload.Bytes())
+	return buf.Bytes()
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(wldBytes(0, ""))
+	payload := make([]byte, 64)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	for _, code := range []int32{0x03, 0x04, 0x05, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x1B, 0x1C, 0x21, 0x22, 0x26, 0x27, 0x28, 0x29, 0x2C, 0x2D, 0x30, 0x31, 0x32, 0x33, 0x34, 0x36} {
+		f.Add(wldBytes(0, "name\x00", fragBytes(code, payload)))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
+
+func TestStringHash(t *testing.T) {
+	hash := fragment.DecodeString([]byte("\x00ZONE_DMSPRITEDEF\x00ZONE_MP\x00"))
+	hash = fragment.DecodeString(hash)
+	if string(hash) != "\x00ZONE_DMSPRITEDEF\x00ZONE_MP\x00" {
+		t.Fatalf("xor decode is not reversible, got %q", hash)
+	}
+
+	// material list named ZONE_MP with no materials
+	data := wldBytes(0, string(hash), fragBytes(0x31, []int32{-18, 0, 0}))
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if wld.Hash[1] != "ZONE_DMSPRITEDEF" {
+		t.Fatalf("hash 1 wanted ZONE_DMSPRITEDEF, got %q", wld.Hash[1])
+	}
+	if wld.Name(-18) != "ZONE_MP" {
+		t.Fatalf("name -18 wanted ZONE_MP, got %q", wld.Name(-18))
+	}
+	if len(wld.Fragments) != 1 {
+		t.Fatalf("wanted 1 fragment, got %d", len(wld.Fragments))
+	}
+	if wld.FragmentByName("zone_mp") != wld.Fragments[0] {
+		t.Fatalf("fragment by name zone_mp not found")
+	}
+}
+
+func TestDecodeMesh(t *testing.T) {
+	mesh := fragBytes(0x36,
+		[]int32{0, 0x00018003, 1, 0, 0, 0},
+		// center, 3 unknowns, max distance, min, max
+		[]float32{10, 20, 30, 0, 0, 0, 5, -1, -1, -1, 1, 1, 1},
+		// vertex, uv, normal, color, polygon, vertex piece, polygon texture, vertex texture, size9 counts then scale
+		[]int16{3, 2, 1, 0, 1, 1, 1, 1, 0, 2},
+		[]int16{4, 8, -12, 0, 0, 0, 400, 0, 0},
+		[]int16{256, 128, 0, 0},
+		[]int8{0, -128, 64},
+		[]uint16{0x10, 0, 1, 40000},
+		[]int16{3, 7},
+		[]uint16{1, 2, 3, 2},
+	)
+
+	wld, err := Decode(bytes.NewReader(wldBytes(0, "", mesh)))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(wld.Fragments) != 1 {
+		t.Fatalf("wanted 1 fragment, got %d", len(wld.Fragments))
+	}
+	m, ok := wld.Fragments[0].(*fragment.Mesh)
+	if !ok {
+		t.Fatalf("wanted mesh, got %T", wld.Fragments[0])
+	}
+	if m.Center.X != 10 || m.Center.Z != 30 || m.MaxDistance != 5 || m.MaxPosition.X != 1 {
+		t.Fatalf("bounds center %v max distance %f max %v", m.Center, m.MaxDistance, m.MaxPosition)
+	}
+	if m.Scale != 2 || len(m.Verticies) != 3 || m.Verticies[0].X != 1 || m.Verticies[0].Z != -3 || m.Verticies[2].X != 100 {
+		t.Fatalf("scale %d vertices %v", m.Scale, m.Verticies)
+	}
+	if len(m.TextureUVCoordinates) != 3 || m.TextureUVCoordinates[0].X != 1 || m.TextureUVCoordinates[0].Y != 0.5 {
+		t.Fatalf("uvs %v", m.TextureUVCoordinates)
+	}
+	if len(m.Normals) != 1 || m.Normals[0].Y != -1 || m.Normals[0].Z != 0.5 {
+		t.Fatalf("normals %v", m.Normals)
+	}
+	if len(m.Indices) != 1 || m.Indices[0].IsSolid || m.Indices[0].Vertex3 != 40000 {
+		t.Fatalf("polygon %+v", m.Indices[0])
+	}
+	if len(m.VertexPieces) != 1 || m.VertexPieces[0].Count != 3 || m.VertexPieces[0].Bone != 7 {
+		t.Fatalf("vertex pieces %+v", m.VertexPieces)
+	}
+	if len(m.RenderGroups) != 1 || m.RenderGroups[0].PolygonCount != 1 || m.RenderGroups[0].MaterialIndex != 2 {
+		t.Fatalf("render groups %+v", m.RenderGroups)
+	}
+	if len(m.VertexTextures) != 1 || m.VertexTextures[0].VertexCount != 3 || m.VertexTextures[0].MaterialIndex != 2 {
+		t.Fatalf("vertex textures %+v", m.VertexTextures)
+	}
+
+	// the scale follows the fragment header, 6 values, 13 floats and 9 counts
+	binary.LittleEndian.PutUint16(mesh[8+24+52+18:], 0xFFFF)
+	_, err = Decode(bytes.NewReader(wldBytes(0, "", mesh)))
+	if err == nil {
+		t.Fatalf("negative scale wanted an error")
+	}
+}
+
+func TestDecodeBitmap(t *testing.T) {
+	fileName := fragment.DecodeString([]byte("SAND.BMP\x00"))
+	data := wldBytes(0, "\x00SAND_MDF\x00",
+		// bitmap name, one file
+		fragBytes(0x03, []uint32{0, 0}, uint16(len(fileName)), fileName),
+		// animated bitmap info with a 100ms delay, both frames sand.bmp
+		fragBytes(0x04, []uint32{0, 0x18, 2, 100, 1, 1}),
+		// bitmap info reference
+		fragBytes(0x05, []uint32{0, 2, 0x50}),
+		// material, diffuse
+		fragBytes(0x30, []int32{-1, 0, 0x01}, []byte{255, 128, 64, 32}, []float32{0.75, 1}, uint32(3)),
+	)
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	info, ok := wld.Fragment(2).(*fragment.BitmapInfo)
+	if !ok {
+		t.Fatalf("fragment 2 wanted bitmap info, got %T", wld.Fragment(2))
+	}
+	if !info.IsAnimated || info.FrameDelay != 100 {
+		t.Fatalf("bitmap info animated %t delay %d", info.IsAnimated, info.FrameDelay)
+	}
+	m, ok := wld.FragmentByName("SAND_MDF").(*fragment.Material)
+	if !ok {
+		t.Fatalf("SAND_MDF wanted material, got %T", wld.FragmentByName("SAND_MDF"))
+	}
+	if m.Brightness != 0.75 || m.Color.R != 255 || m.ShaderType != fragment.ShaderTypeDiffuse {
+		t.Fatalf("material brightness %f color %v shader %d", m.Brightness, m.Color, m.ShaderType)
+	}
+	files := wld.BitmapFiles(m)
+	if len(files) != 2 || files[0] != "SAND.BMP" {
+		t.Fatalf("bitmap files %v", files)
+	}
+}
+
+func TestDecodeSkeleton(t *testing.T) {
+	data := wldBytes(0, "\x00HUM_HS_DEF\x00HUM_PE_DAG\x00HUM_HE_DAG\x00",
+		// track with one frame, moved 1 on x
+		fragBytes(0x12, []uint32{0, 8, 1}, []int16{1, 0, 0, 0, 256, 0, 0, 256}),
+		// track reference
+		fragBytes(0x13, []uint32{0, 1, 0}),
+		// skeleton with a bounding radius, pelvis and a head child
+		fragBytes(0x10, []int32{-1, 0x2, 2, 0}, float32(4.5), []int32{-12, 0, 2, 0, 1, 1}, []int32{-23, 0, 2, 0, 0}),
+	)
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	skel, ok := wld.FragmentByName("HUM_HS_DEF").(*fragment.SkeletonHierarchy)
+	if !ok {
+		t.Fatalf("HUM_HS_DEF wanted skeleton hierarchy, got %T", wld.FragmentByName("HUM_HS_DEF"))
+	}
+	if skel.BoundingRadius != 4.5 || len(skel.Bones) != 2 {
+		t.Fatalf("bounding radius %f bones %d", skel.BoundingRadius, len(skel.Bones))
+	}
+	head := skel.Bones[1]
+	if head.Name != "HUM_HE_DAG" || head.Parent != 0 || skel.Bones[0].Parent != -1 {
+		t.Fatalf("head %+v", head)
+	}
+	track := wld.BoneTrack(head)
+	if track == nil || len(track.Frames) != 1 {
+		t.Fatalf("head track %v", track)
+	}
+	if track.Frames[0].Translation.X != 1 || track.Frames[0].Scale != 1 || track.Frames[0].Rotation.W != 1 {
+		t.Fatalf("frame %+v", track.Frames[0])
+	}
+}
+
+func TestDecodeActor(t *testing.T) {
+	data := wldBytes(0, "\x00TREE_ACTORDEF\x00SPRITECALLBACK\x00TREE_DMSPRITEDEF\x00",
+		// empty mesh with a max distance of 7
+		fragBytes(0x36, []int32{-30, 0x00014003, 0, 0, 0, 0}, []float32{0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0}, make([]int16, 10)),
+		// mesh reference
+		fragBytes(0x2D, []uint32{0, 1, 0}),
+		// actor with one action of one level of detail and no user data
+		fragBytes(0x14, []int32{-1, 0, -15, 1, 1, 0, 1, 0}, float32(100), []uint32{2, 0}),
+		// object instance placing the actor
+		fragBytes(0x15, []int32{0, -1, 0x32E, 0}, []float32{1, 2, 3, 0, 0, 0, 1, 1, 1}, uint32(0)),
+	)
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	obj, ok := wld.Fragment(4).(*fragment.ObjectInstance)
+	if !ok {
+		t.Fatalf("fragment 4 wanted object instance, got %T", wld.Fragment(4))
+	}
+	if obj.ActorName != "TREE_ACTORDEF" {
+		t.Fatalf("actor name wanted TREE_ACTORDEF, got %q", obj.ActorName)
+	}
+	actor := wld.Actor(obj)
+	if actor == nil {
+		t.Fatalf("actor not found")
+	}
+	if actor.CallbackName != "SPRITECALLBACK" || len(actor.Actions) != 1 || actor.Actions[0].MinDistances[0] != 100 {
+		t.Fatalf("actor %+v", actor)
+	}
+	if wld.ActorSkeleton(actor) != nil {
+		t.Fatalf("static actor has a skeleton")
+	}
+	meshes := wld.ActorMeshes(actor)
+	if len(meshes) != 1 || meshes[0].Name() != "TREE_DMSPRITEDEF" {
+		t.Fatalf("meshes %v", meshes)
+	}
+	if wld.ActorBoundingRadius(actor) != 7 {
+		t.Fatalf("bounding radius wanted 7, got %f", wld.ActorBoundingRadius(actor))
+	}
+}
+
+func TestDecodeBsp(t *testing.T) {
+	region := func(pvs []byte, radius float32) []byte {
+		return fragBytes(0x22, []uint32{0, 0x81, 0, 0, 0, 0, 0, 0, 0, 0, 1}, uint16(len(pvs)), pvs, []float32{0, 0, 0, radius}, uint32(0))
+	}
+	data := wldBytes(2, "",
+		// tree split on x, region 1 in front and region 2 behind
+		fragBytes(0x21, []uint32{0, 3}, []float32{1, 0, 0, 0}, []uint32{0, 2, 3}, []float32{0, 0, 0, 0}, []uint32{1, 0, 0}, []float32{0, 0, 0, 0}, []uint32{2, 0, 0}),
+		// sees itself and region 2
+		region([]byte{0xC2}, 10),
+		// skips 2, skips 1 includes 2, includes 1, includes 2 skips 1, includes 1
+		region([]byte{0x3F, 2, 0, 0x4A, 0xFF, 1, 0, 0x91, 0xC1}, 20),
+	)
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(wld.Regions()) != 2 || wld.Regions()[1].Sphere.Radius != 20 {
+		t.Fatalf("regions %v", wld.Regions())
+	}
+	if got := wld.RegionAt(math32.Vector3{X: 5}); got != 0 {
+		t.Fatalf("region at x 5 wanted 0, got %d", got)
+	}
+	if got := wld.RegionAt(math32.Vector3{X: -5}); got != 1 {
+		t.Fatalf("region at x -5 wanted 1, got %d", got)
+	}
+	visible, err := wld.VisibleRegions(0)
+	if got := fmt.Sprint(visible); err != nil || got != "[0 1]" {
+		t.Fatalf("visible from 0 wanted [0 1], got %s %v", got, err)
+	}
+	visible, err = wld.VisibleRegions(1)
+	if got := fmt.Sprint(visible); err != nil || got != "[3 4 5 6 7 9]" {
+		t.Fatalf("visible from 1 wanted [3 4 5 6 7 9], got %s %v", got, err)
+	}
+	_, err = wld.VisibleRegions(2)
+	if err == nil {
+		t.Fatalf("region 2 does not exist, wanted an error")
+	}
+
+	// a pvs of words is not decoded
+	data = wldBytes(1, "", fragBytes(0x22, []uint32{0, 0x21, 0, 0, 0, 0, 0, 0, 0, 0, 1}, uint16(1), uint16(1), []float32{0, 0, 0, 10}, uint32(0)))
+	wld, err = Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode words: %v", err)
+	}
+	_, err = wld.VisibleRegions(0)
+	if err == nil {
+		t.Fatalf("pvs of words wanted an error")
+	}
+}
+
+func TestDecodeRegionFlag(t *testing.T) {
+	// the user data of the last overrides its name
+	userData := fragment.DecodeString([]byte("DRNTP00255000007_ZONE\x00"))
+	data := wldBytes(0, "\x00WT_ZONE\x00DRNTP00002-00400000150000030064_ZONE\x00DRN_ZONE\x00",
+		fragBytes(0x29, []int32{-1, 0, 2, 0, 3, 0}),
+		fragBytes(0x29, []int32{-9, 0, 1, 3, 0}),
+		fragBytes(0x29, []int32{-46, 0, 1, 1, int32(len(userData))}, userData),
+	)
+
+	wld, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := fmt.Sprint(wld.RegionTypes(3)); got != "[water zone line]" {
+		t.Fatalf("region 3 wanted [water zone line], got %s", got)
+	}
+	if got := len(wld.RegionTypes(2)); got != 0 {
+		t.Fatalf("region 2 wanted no types, got %d", got)
+	}
+	flags := wld.RegionFlags()
+	if len(flags) != 3 {
+		t.Fatalf("wanted 3 region flags, got %d", len(flags))
+	}
+	zl, err := flags[1].ZoneLine()
+	if err != nil {
+		t.Fatalf("zone line: %v", err)
+	}
+	if zl.ZoneID != 2 || zl.Position.X != -400 || zl.Position.Y != 150 || zl.Position.Z != 30 || zl.Heading != 64 {
+		t.Fatalf("zone line %+v", zl)
+	}
+	zl, err = flags[2].ZoneLine()
+	if err != nil {
+		t.Fatalf("zone line reference: %v", err)
+	}
+	if zl.ZoneID != 255 || zl.PointIndex != 7 {
+		t.Fatalf("zone line reference %+v", zl)
+	}
+	zl, err = flags[0].ZoneLine()
+	if err != nil || zl != nil {
+		t.Fatalf("water is not a zone line, got %v %v", zl, err)
+	}
+}
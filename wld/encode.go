@@ -0,0 +1,91 @@
+package wld
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// Encode writes a world file to w in the old or new format set by IsOldWorld.
+// The string hash keeps the names of Hash at their offsets and adds any other names of the fragments, so the name
+// references of object instances, actors and skeleton bones are set from their names as they are written
+func (wld *Wld) Encode(w io.WriteSeeker) error {
+	hash := newStringHashOf(wld.Hash, wld.hashSize)
+	nameRefs := make([]int32, len(wld.Fragments))
+	bspRegionCount := uint32(0)
+	for i, frag := range wld.Fragments {
+		if frag == nil {
+			return fmt.Errorf("fragment %d is nil", i+1)
+		}
+		for _, ref := range frag.References() {
+			if int(ref) > len(wld.Fragments) {
+				return fmt.Errorf("%s %d references fragment %d of %d", frag.FragmentType(), i+1, ref, len(wld.Fragments))
+			}
+		}
+		nameRefs[i] = hash.ref(frag.Name())
+		switch v := frag.(type) {
+		case *fragment.ObjectInstance:
+			v.ActorReference = hash.ref(v.ActorName)
+		case *fragment.Actor:
+			v.CallbackReference = hash.ref(v.CallbackName)
+		case *fragment.SkeletonHierarchy:
+			for _, bone := range v.Bones {
+				bone.NameReference = hash.ref(bone.Name)
+			}
+		case *fragment.Mesh:
+			v.IsNewWorldFormat = !wld.IsOldWorld
+		case *fragment.BspRegion:
+			bspRegionCount++
+		case *fragment.UnknownFragment:
+			v.NameReference = nameRefs[i]
+		}
+	}
+
+	version := uint32(0x1000C800)
+	if wld.IsOldWorld {
+		version = 0x00015500
+	}
+	err := binary.Write(w, binary.LittleEndian, []uint32{0x54503D02, version, uint32(len(wld.Fragments)), bspRegionCount, 0, uint32(len(hash.data)), 0})
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	_, err = w.Write(fragment.DecodeString(hash.data))
+	if err != nil {
+		return fmt.Errorf("write hash: %w", err)
+	}
+
+	payload := &bytes.Buffer{}
+	for i, frag := range wld.Fragments {
+		payload.Reset()
+		err = binary.Write(payload, binary.LittleEndian, nameRefs[i])
+		if err != nil {
+			return fmt.Errorf("write name reference %d/%d: %w", i, len(wld.Fragments), err)
+		}
+		err = frag.Write(payload)
+		if err != nil {
+			return fmt.Errorf("write %s %d/%d: %w", frag.FragmentType(), i, len(wld.Fragments), err)
+		}
+		// fragments are padded to 4 bytes
+		for payload.Len()%4 != 0 {
+			payload.WriteByte(0)
+		}
+
+		err = binary.Write(w, binary.LittleEndian, []uint32{uint32(payload.Len()), uint32(frag.FragmentCode())})
+		if err != nil {
+			return fmt.Errorf("write fragment header %d/%d: %w", i, len(wld.Fragments), err)
+		}
+		_, err = w.Write(payload.Bytes())
+		if err != nil {
+			return fmt.Errorf("write fragment %d/%d: %w", i, len(wld.Fragments), err)
+		}
+	}
+
+	wld.FragmentCount = uint32(len(wld.Fragments))
+	wld.BspRegionCount = bspRegionCount
+	wld.Hash = parseStringHash(hash.data)
+	wld.hashSize = len(hash.data)
+	return nil
+}
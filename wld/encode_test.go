@@ -0,0 +1,381 @@
+package wld
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// bufferWriteSeeker lets tests encode to memory, encoding only ever appends
+type bufferWriteSeeker struct {
+	bytes.Buffer
+}
+
+func (b *bufferWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	return int64(b.Len()), nil
+}
+
+func encodeBytes(t *testing.T, w *Wld) []byte {
+	buf := &bufferWriteSeeker{}
+	err := w.Encode(buf)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// fragmentPayloads returns the payload of every fragment of an encoded wld, name reference included
+func fragmentPayloads(t *testing.T, data []byte) [][]byte {
+	if len(data) < 28 {
+		t.Fatalf("wld of %d bytes has no header", len(data))
+	}
+	count := binary.LittleEndian.Uint32(data[8:])
+	offset := 28 + int(binary.LittleEndian.Uint32(data[20:]))
+	payloads := [][]byte{}
+	for i := uint32(0); i < count; i++ {
+		if offset+8 > len(data) {
+			t.Fatalf("fragment %d header passes the end", i)
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 8
+		if offset+size > len(data) {
+			t.Fatalf("fragment %d size %d passes the end", i, size)
+		}
+		payloads = append(payloads, data[offset:offset+size])
+		offset += size
+	}
+	return payloads
+}
+
+func TestEncodeNexus(t *testing.T) {
+	path := "test/nexus.wld"
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Skipf("%s is not present", path)
+	}
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+
+	in, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := fragmentPayloads(t, data)
+	got := fragmentPayloads(t, encodeBytes(t, in))
+	if len(got) != len(want) {
+		t.Fatalf("%d fragments, wanted %d", len(got), len(want))
+	}
+	for i, frag := range in.Fragments {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Fatalf("%s %d %s encoded\n%x\nwanted\n%x", frag.FragmentType(), i+1, frag.Name(), got[i], want[i])
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	// hashes that are empty, padded and without a final null are written as long as decoded
+	for _, hash := range []string{"", "\x00ABC\x00\x00\x00", "\x00ABC"} {
+		data := wldBytes(0, hash)
+		w, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode hash %q: %v", hash, err)
+		}
+		if got := encodeBytes(t, w); !bytes.Equal(got, data) {
+			t.Fatalf("hash %q encoded\n%x\nwanted\n%x", hash, got, data)
+		}
+	}
+
+	data := wldBytes(1, "\x00TREE_ACTORDEF\x00SPRITECALLBACK\x00HUM_HS_DEF\x00HUM_PE_DAG\x00",
+		// skeleton with unknown params, a bounding radius and a mesh reference followed by its unknown
+		fragBytes(0x10, []int32{-30, 0x203, 1, 0}, []uint32{7, 8, 9}, float32(2.5), []int32{-41, 0, 0, 0, 0}, []uint32{1, 3, 5}),
+		// actor with user data
+		fragBytes(0x14, []int32{-1, 0, -15, 1, 1, 0}, []uint32{1, 0}, float32(100), uint32(1), uint32(8), []byte("userdata")),
+		// object instance with a third rotation and a scale that differs per axis
+		fragBytes(0x15, []int32{0, -1, 0x32E, 0}, []float32{1, 2, 3, 64, 128, 32, 0.5, 2, 1.5}, uint32(0)),
+		// material with the high bit of its params set
+		fragBytes(0x30, []int32{0, 0x2}, uint32(0x80000001), []byte{1, 2, 3, 4}, []float32{0.5, 1}, uint32(0)),
+		// mesh with unknowns after the references and center, fewer uvs than vertices, a polygon flagged 0x11 and a size9 entry
+		fragBytes(0x36, []int32{0, 0x00014003, 0, 0, 5, 6}, []float32{10, 20, 30}, []uint32{1, 2, 3}, make([]float32, 7),
+			[]int16{4, 1, 0, 0, 1, 0, 0, 0, 1, 2}, []int16{4, 8, 12, 0, 0, 0, -4, 0, 0, 0, 4, 0}, []int16{256, 128},
+			[]uint16{0x11, 0, 1, 2}, []byte("twelve bytes")),
+		// region with data1, data3 and data5 sections, both reverb values and user data
+		fragBytes(0x22, []int32{0, 0x87, 0}, []uint32{1, 0, 9, 1, 0, 10, 1, 1}, []float32{1, 2, 3}, []uint32{1, 1, 2}, make([]float32, 7),
+			uint16(1), byte(0xC1), []float32{0, 0, 0, 50}, []uint32{3, 4}, uint32(5), []byte("data5")),
+		// track with a frame without a rotation or shift denominator, and one off the rounding of its values
+		fragBytes(0x12, []int32{0, 8, 2}, []int16{0, 0, 0, 0, 1, 2, 3, 0}, []int16{3, 1, 2, 5, 256, 0, -256, 300}),
+	)
+
+	w, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := encodeBytes(t, w); !bytes.Equal(got, data) {
+		t.Fatalf("encoded\n%x\nwanted\n%x", got, data)
+	}
+
+	if got := w.Fragment(7).(*fragment.Track).Frames[0].Rotation; got != (math32.Quaternion{W: 1}) {
+		t.Fatalf("frame without a rotation %v", got)
+	}
+
+	// an edited rotation is written from its degrees
+	obj := w.Fragment(3).(*fragment.ObjectInstance)
+	obj.Rotation.Y = 90
+	w, err = Decode(bytes.NewReader(encodeBytes(t, w)))
+	if err != nil {
+		t.Fatalf("decode edited: %v", err)
+	}
+	if got := w.Fragment(3).(*fragment.ObjectInstance).Rotation; got.Y != 90 || got.Z != obj.Rotation.Z {
+		t.Fatalf("edited rotation %v, wanted %v", got, obj.Rotation)
+	}
+}
+
+// testWld has one of every fragment that can be written, with values that are stored exactly
+func testWld(isOldWorld bool) *Wld {
+	w := &Wld{IsOldWorld: isOldWorld}
+
+	bitmap := &fragment.BitmapName{Files: []string{"sand.bmp"}}
+	bitmapRef := w.Add(bitmap)
+	info := &fragment.BitmapInfo{IsAnimated: true, FrameDelay: 100, BitmapNameReferences: []uint32{bitmapRef, bitmapRef}}
+	info.SetName("SAND_SPRITE")
+	infoRef := w.Add(&fragment.BitmapInfoReference{Reference: w.Add(info), Flags: 0x50})
+	material := &fragment.Material{
+		Flags:               0x2,
+		BitmapInfoReference: infoRef,
+		Color:               color.RGBA{R: 255, G: 128, B: 64, A: 32},
+		Brightness:          0.75,
+		ScaledAmbient:       1,
+		MaterialType:        fragment.MaterialTypeTransparentMasked,
+	}
+	material.SetName("SAND_MDF")
+	list := &fragment.MaterialList{MaterialReferences: []uint32{w.Add(material)}}
+	list.SetName("ZONE_MP")
+
+	mesh := &fragment.Mesh{
+		Flags:             0x00014003,
+		MaterialReference: w.Add(list),
+		Center:            math32.Vector3{X: 10, Y: 20, Z: 30},
+		MaxDistance:       5,
+		Scale:             2,
+		MinPosition:       math32.Vector3{X: -1, Y: -1, Z: -1},
+		MaxPosition:       math32.Vector3{X: 1, Y: 1, Z: 1},
+		Verticies:         []math32.Vector3{{X: 1, Y: 2, Z: -3}, {X: 0.25}, {Z: 100}},
+		TextureUVCoordinates: []math32.Vector2{
+			{X: 1, Y: 0.5}, {X: 100}, {Y: -2},
+		},
+		Normals: []math32.Vector3{{Y: -1, Z: 0.5}},
+		Colors:  []color.RGBA{{R: 1, G: 2, B: 3, A: 4}},
+		Indices: []*fragment.Polygon{
+			{IsSolid: true, Vertex1: 0, Vertex2: 1, Vertex3: 2},
+			{Vertex1: 2, Vertex2: 1, Vertex3: 0},
+		},
+		VertexPieces:   []*fragment.MeshVertexPiece{{Count: 1, Bone: 0}, {Start: 1, Count: 2, Bone: 1}},
+		RenderGroups:   []*fragment.MeshRenderGroup{{PolygonCount: 2, MaterialIndex: 0}},
+		VertexTextures: []*fragment.MeshVertexTexture{{VertexCount: 3, MaterialIndex: 0}},
+	}
+	mesh.SetName("HUM_DMSPRITEDEF")
+	meshRef := w.Add(&fragment.MeshReference{Reference: w.Add(mesh)})
+
+	track := &fragment.Track{Frames: []*fragment.BoneTransform{
+		{Translation: math32.Vector3{X: 1}, Rotation: math32.Quaternion{W: 1}, Scale: 1},
+		{Translation: math32.Vector3{Z: -0.5}, Rotation: math32.Quaternion{Z: 1}, Scale: 2},
+	}}
+	track.SetName("HUM_PE_TRACK")
+	trackRef := &fragment.TrackReference{Reference: w.Add(track), FrameMs: 50}
+	trackRef.SetName("HUM_PE_TRACK")
+	skel := &fragment.SkeletonHierarchy{
+		Flags:          0x2,
+		BoundingRadius: 4.5,
+		Bones: []*fragment.SkeletonBone{
+			{Name: "HUM_PE_DAG", TrackReference: w.Add(trackRef), Parent: -1, Children: []int{1}},
+			{Name: "HUM_HE_DAG", MeshReference: meshRef, Parent: 0},
+		},
+		MeshReferences: []uint32{meshRef},
+	}
+	skel.SetName("HUM_HS_DEF")
+	skelRef := w.Add(&fragment.SkeletonReference{Reference: w.Add(skel)})
+
+	actor := &fragment.Actor{
+		Flags:            0x1,
+		CallbackName:     "SPRITECALLBACK",
+		CurrentAction:    3,
+		Actions:          []*fragment.ActorAction{{MinDistances: []float32{100, 200}}},
+		SpriteReferences: []uint32{skelRef},
+	}
+	actor.SetName("HUM_ACTORDEF")
+	w.Add(actor)
+	w.Add(&fragment.ObjectInstance{
+		ActorName:            "HUM_ACTORDEF",
+		Flags:                0x2E,
+		Position:             math32.Vector3{X: 1, Y: 2, Z: 3},
+		Rotation:             math32.Vector3{Y: 90, Z: -45},
+		Scale:                math32.Vector3{X: 2, Y: 2, Z: 2},
+		VertexColorReference: 0,
+	})
+
+	w.Add(&fragment.BspTree{Nodes: []*fragment.BspNode{
+		{Normal: math32.Vector3{X: 1}, Front: 2, Back: 3},
+		{RegionID: 1},
+		{RegionID: 2},
+	}})
+	region := &fragment.BspRegion{Flags: 0x81, PVS: [][]byte{{0xC2}}, Sphere: fragment.BspSphere{Radius: 10}, HasPolygons: true, Reference: meshRef}
+	region.SetName("R000001")
+	w.Add(region)
+	w.Add(&fragment.BspRegion{Flags: 0xA7, PVS: [][]byte{{1, 0}}})
+	flag := &fragment.RegionFlag{RegionIndices: []int{0, 1}, UserData: "DRNTP00255000007_ZONE"}
+	flag.SetName("DRN_ZONE")
+	w.Add(flag)
+
+	light := &fragment.LightSource{IsPlacedLightSource: true, IsColoredLight: true, Attentuation: 200, Color: color.RGBA{R: 10, G: 20, B: 30, A: 40}}
+	light.SetName("TORCH_LDEF")
+	lightRef := w.Add(&fragment.LightSourceReference{Reference: w.Add(light)})
+	w.Add(&fragment.LightInstance{Reference: lightRef, Flags: 0x100, Position: math32.Vector3{X: 5}, Radius: 20})
+	w.Add(&fragment.LightSource{})
+
+	w.Add(&fragment.VertexColorReference{Reference: w.Add(&fragment.VertexColor{Colors: []color.RGBA{{R: 1, G: 2, B: 3, A: 4}, {A: 255}}})})
+	sprite := w.Add(&fragment.ParticleSprite{Reference: bitmapRef})
+	w.Add(&fragment.ParticleSpriteReference{Reference: sprite})
+	w.Add(&fragment.ParticleCloud{})
+	return w
+}
+
+func TestEncode(t *testing.T) {
+	for _, isOldWorld := range []bool{true, false} {
+		in := testWld(isOldWorld)
+		data := encodeBytes(t, in)
+
+		out, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("old world %t decode: %v", isOldWorld, err)
+		}
+		if out.IsOldWorld != isOldWorld || len(out.Fragments) != len(in.Fragments) || out.BspRegionCount != 2 {
+			t.Fatalf("old world %t, %d fragments wanted %d, %d regions", out.IsOldWorld, len(out.Fragments), len(in.Fragments), out.BspRegionCount)
+		}
+		for i, frag := range in.Fragments {
+			if out.Fragments[i].FragmentType() != frag.FragmentType() {
+				t.Fatalf("fragment %d wanted %s, got %s", i, frag.FragmentType(), out.Fragments[i].FragmentType())
+			}
+		}
+
+		m, ok := out.FragmentByName("SAND_MDF").(*fragment.Material)
+		if !ok || m.ShaderType != fragment.ShaderTypeTransparentMasked || m.Color.G != 128 || m.Flags != 0x2 {
+			t.Fatalf("material %+v", out.FragmentByName("SAND_MDF"))
+		}
+		if files := out.BitmapFiles(m); len(files) != 2 || files[1] != "sand.bmp" {
+			t.Fatalf("bitmap files %v", files)
+		}
+
+		mesh := out.FragmentByName("HUM_DMSPRITEDEF").(*fragment.Mesh)
+		if mesh.Verticies[0] != (math32.Vector3{X: 1, Y: 2, Z: -3}) || mesh.Verticies[1].X != 0.25 || mesh.Flags != 0x00014003 {
+			t.Fatalf("vertices %v flags 0x%x", mesh.Verticies, mesh.Flags)
+		}
+		if mesh.TextureUVCoordinates[1].X != 100 || mesh.TextureUVCoordinates[2].Y != -2 || mesh.Normals[0].Z != 0.5 {
+			t.Fatalf("uvs %v normals %v", mesh.TextureUVCoordinates, mesh.Normals)
+		}
+		if !mesh.Indices[0].IsSolid || mesh.Indices[1].IsSolid || mesh.Indices[1].Vertex1 != 2 || mesh.VertexPieces[1].Bone != 1 {
+			t.Fatalf("polygons %+v %+v", mesh.Indices[0], mesh.Indices[1])
+		}
+
+		skel := out.FragmentByName("HUM_HS_DEF").(*fragment.SkeletonHierarchy)
+		// the track reference of the pelvis, the mesh reference on the head and the skinned mesh
+		if got := fmt.Sprint(skel.References()); skel.FragmentCode() != 0x10 || got != "[9 7 7]" {
+			t.Fatalf("skeleton 0x%x references %s", skel.FragmentCode(), got)
+		}
+		if skel.BoundingRadius != 4.5 || skel.Bones[1].Name != "HUM_HE_DAG" || skel.Bones[1].Parent != 0 || len(skel.MeshReferences) != 1 {
+			t.Fatalf("skeleton %+v", skel)
+		}
+		frame := out.BoneTrack(skel.Bones[0]).Frames[1]
+		if frame.Translation.Z != -0.5 || frame.Rotation.Z != 1 || frame.Scale != 2 {
+			t.Fatalf("frame %+v", frame)
+		}
+		if ref := out.Fragment(skel.Bones[0].TrackReference).(*fragment.TrackReference); ref.FrameMs != 50 {
+			t.Fatalf("track reference frame ms %d", ref.FrameMs)
+		}
+
+		obj := out.Fragments[12].(*fragment.ObjectInstance)
+		actor := out.Actor(obj)
+		if actor == nil || actor.CallbackName != "SPRITECALLBACK" || actor.CurrentAction != 3 || actor.Actions[0].MinDistances[1] != 200 {
+			t.Fatalf("actor %+v", actor)
+		}
+		if obj.Rotation.Y != 90 || obj.Rotation.Z != -45 || obj.Scale.X != 2 || obj.Position.Z != 3 {
+			t.Fatalf("object instance %+v", obj)
+		}
+
+		if got := out.RegionAt(math32.Vector3{X: -5}); got != 1 {
+			t.Fatalf("region at x -5 wanted 1, got %d", got)
+		}
+		regions := out.Regions()
+		if !regions[0].HasPolygons || regions[0].Sphere.Radius != 10 || len(regions[0].VisibleRegions) != 2 || len(regions[1].PVS[0]) != 2 {
+			t.Fatalf("regions %+v %+v", regions[0], regions[1])
+		}
+		if zl, err := out.RegionFlags()[0].ZoneLine(); err != nil || zl.PointIndex != 7 {
+			t.Fatalf("zone line %+v %v", zl, err)
+		}
+
+		light := out.FragmentByName("TORCH_LDEF").(*fragment.LightSource)
+		if !light.IsColoredLight || light.Attentuation != 200 || light.Color.B != 30 {
+			t.Fatalf("light %+v", light)
+		}
+		colors := out.Fragments[21].(*fragment.VertexColor)
+		if len(colors.Colors) != 2 || colors.Colors[0] != (color.RGBA{R: 1, G: 2, B: 3, A: 4}) {
+			t.Fatalf("vertex colors %v", colors.Colors)
+		}
+
+		// what is decoded writes back the same
+		if !bytes.Equal(encodeBytes(t, out), data) {
+			t.Fatalf("old world %t decoded wld does not encode the same", isOldWorld)
+		}
+	}
+
+	// uvs are 32 bit in the new format, and there are 3
+	if old, new := len(encodeBytes(t, testWld(true))), len(encodeBytes(t, testWld(false))); new-old != 12 {
+		t.Fatalf("old world %d bytes, new world %d", old, new)
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	fileName := fragment.DecodeString([]byte("sand.bmp\x00"))
+	data := wldBytes(0, "\x00SAND.BMP\x00ZONE_MP\x00",
+		// global ambient light, a type not understood
+		fragBytes(0x35, int32(0), []byte{10, 20, 30, 255}),
+		// bitmap name with one file, padded to 4 bytes
+		fragBytes(0x03, []int32{-1, 0}, uint16(len(fileName)), fileName, uint8(0)),
+		// material list
+		fragBytes(0x31, []int32{-10, 0x1, 0}),
+	)
+
+	logs := []string{}
+	w, err := DecodeWithOptions(bytes.NewReader(data), DecodeOptions{Logf: func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("logs %v", logs)
+	}
+	unknown, ok := w.Fragment(1).(*fragment.UnknownFragment)
+	if !ok || unknown.FragmentCode() != 0x35 || !bytes.Equal(unknown.Data, []byte{10, 20, 30, 255}) {
+		t.Fatalf("fragment 1 wanted the unknown fragment, got %+v", w.Fragment(1))
+	}
+	// the unknown fragment still counts toward references
+	if _, ok := w.Fragment(2).(*fragment.BitmapName); !ok {
+		t.Fatalf("fragment 2 wanted bitmap name, got %T", w.Fragment(2))
+	}
+	if got := encodeBytes(t, w); !bytes.Equal(got, data) {
+		t.Fatalf("encoded\n%x\nwanted\n%x", got, data)
+	}
+
+	w = &Wld{IsOldWorld: true}
+	w.Add(&fragment.MaterialList{MaterialReferences: []uint32{2}})
+	err = w.Encode(&bufferWriteSeeker{})
+	if err == nil {
+		t.Fatalf("reference past the last fragment wanted an error")
+	}
+}
@@ -0,0 +1,224 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Actor information, the definition of a placeable model such as TREE_ACTORDEF or HUM_ACTORDEF
+type Actor struct {
+	hashIndex uint32
+	name      string
+	Flags     uint32
+	// CallbackReference is the negative string hash offset of the callback, usually SPRITECALLBACK
+	CallbackReference int32
+	// CallbackName is resolved from CallbackReference when the wld is decoded
+	CallbackName string
+	// BoundsReference points to a fragment bounding the actor, 0 if none
+	BoundsReference uint32
+	CurrentAction   uint32
+	Location        ActorLocation
+	Actions         []*ActorAction
+	// SpriteReferences point to what is drawn, such as a mesh reference for objects or a skeleton reference for characters
+	SpriteReferences []uint32
+	// userData is the encoded user data string as stored
+	userData []byte
+}
+
+// ActorLocation is set when bit 1 of an actor's flags is set
+type ActorLocation struct {
+	X, Y, Z                   float32
+	RotateZ, RotateY, RotateX float32
+	Unknown                   uint32
+}
+
+// ActorAction is a set of levels of detail, one per sprite
+type ActorAction struct {
+	Unknown uint32
+	// MinDistances are how far away each level of detail starts
+	MinDistances []float32
+}
+
+func LoadActor(r io.ReadSeeker) (*Actor, error) {
+	v := &Actor{}
+	err := parseActor(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse actor: %w", err)
+	}
+	return v, nil
+}
+
+func parseActor(r io.ReadSeeker, v *Actor) error {
+	if v == nil {
+		return fmt.Errorf("actor is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.CallbackReference)
+	if err != nil {
+		return fmt.Errorf("read callback reference: %w", err)
+	}
+
+	var actionCount uint32
+	err = binary.Read(r, binary.LittleEndian, &actionCount)
+	if err != nil {
+		return fmt.Errorf("read action count: %w", err)
+	}
+
+	var spriteCount uint32
+	err = binary.Read(r, binary.LittleEndian, &spriteCount)
+	if err != nil {
+		return fmt.Errorf("read sprite count: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.BoundsReference)
+	if err != nil {
+		return fmt.Errorf("read bounds reference: %w", err)
+	}
+
+	if v.Flags&0x1 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.CurrentAction)
+		if err != nil {
+			return fmt.Errorf("read current action: %w", err)
+		}
+	}
+
+	if v.Flags&0x2 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.Location)
+		if err != nil {
+			return fmt.Errorf("read location: %w", err)
+		}
+	}
+
+	for i := uint32(0); i < actionCount; i++ {
+		var levelCount uint32
+		err = binary.Read(r, binary.LittleEndian, &levelCount)
+		if err != nil {
+			return fmt.Errorf("read action %d level count: %w", i, err)
+		}
+		action := &ActorAction{}
+		err = binary.Read(r, binary.LittleEndian, &action.Unknown)
+		if err != nil {
+			return fmt.Errorf("read action %d unknown: %w", i, err)
+		}
+		for j := uint32(0); j < levelCount; j++ {
+			var distance float32
+			err = binary.Read(r, binary.LittleEndian, &distance)
+			if err != nil {
+				return fmt.Errorf("read action %d level %d: %w", i, j, err)
+			}
+			action.MinDistances = append(action.MinDistances, distance)
+		}
+		v.Actions = append(v.Actions, action)
+	}
+
+	for i := uint32(0); i < spriteCount; i++ {
+		var value uint32
+		err = binary.Read(r, binary.LittleEndian, &value)
+		if err != nil {
+			return fmt.Errorf("read %d sprite reference: %w", i, err)
+		}
+		v.SpriteReferences = append(v.SpriteReferences, value)
+	}
+
+	var userDataSize uint32
+	err = binary.Read(r, binary.LittleEndian, &userDataSize)
+	if err != nil {
+		return fmt.Errorf("read user data size: %w", err)
+	}
+	if userDataSize == 0 {
+		return nil
+	}
+	v.userData = make([]byte, userDataSize)
+	_, err = io.ReadFull(r, v.userData)
+	if err != nil {
+		return fmt.Errorf("read user data: %w", err)
+	}
+	return nil
+}
+
+func (v *Actor) FragmentType() string {
+	return "Actor"
+}
+
+func (v *Actor) FragmentCode() int32 {
+	return 0x14
+}
+
+func (v *Actor) Name() string {
+	return v.name
+}
+
+func (v *Actor) SetName(name string) {
+	v.name = name
+}
+
+func (v *Actor) References() []uint32 {
+	return append(references(v.BoundsReference), v.SpriteReferences...)
+}
+
+func (v *Actor) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.Flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.CallbackReference)
+	if err != nil {
+		return fmt.Errorf("write callback reference: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, []uint32{uint32(len(v.Actions)), uint32(len(v.SpriteReferences)), v.BoundsReference})
+	if err != nil {
+		return fmt.Errorf("write counts: %w", err)
+	}
+
+	if v.Flags&0x1 != 0 {
+		err = binary.Write(w, binary.LittleEndian, v.CurrentAction)
+		if err != nil {
+			return fmt.Errorf("write current action: %w", err)
+		}
+	}
+
+	if v.Flags&0x2 != 0 {
+		err = binary.Write(w, binary.LittleEndian, &v.Location)
+		if err != nil {
+			return fmt.Errorf("write location: %w", err)
+		}
+	}
+
+	for i, action := range v.Actions {
+		err = binary.Write(w, binary.LittleEndian, []uint32{uint32(len(action.MinDistances)), action.Unknown})
+		if err != nil {
+			return fmt.Errorf("write action %d: %w", i, err)
+		}
+		err = binary.Write(w, binary.LittleEndian, action.MinDistances)
+		if err != nil {
+			return fmt.Errorf("write action %d levels: %w", i, err)
+		}
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.SpriteReferences)
+	if err != nil {
+		return fmt.Errorf("write sprite references: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(len(v.userData)))
+	if err != nil {
+		return fmt.Errorf("write user data size: %w", err)
+	}
+	_, err = w.Write(v.userData)
+	if err != nil {
+		return fmt.Errorf("write user data: %w", err)
+	}
+	return nil
+}
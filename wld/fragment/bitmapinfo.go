@@ -0,0 +1,116 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// BitmapInfo information, a list of bitmap names that are animated if there are more than one
+type BitmapInfo struct {
+	hashIndex uint32
+	name      string
+	Flags     uint32
+	// IsAnimated is set when the bitmap names are frames cycled every FrameDelay milliseconds
+	IsAnimated bool
+	FrameDelay uint32
+	// BitmapNameReferences point to bitmap name fragments, one per frame
+	BitmapNameReferences []uint32
+}
+
+func LoadBitmapInfo(r io.ReadSeeker) (*BitmapInfo, error) {
+	v := &BitmapInfo{}
+	err := parseBitmapInfo(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse BitmapInfo: %w", err)
+	}
+	return v, nil
+}
+
+func parseBitmapInfo(r io.ReadSeeker, v *BitmapInfo) error {
+	if v == nil {
+		return fmt.Errorf("BitmapInfo is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+	v.IsAnimated = v.Flags&0x08 != 0
+
+	var bitmapCount uint32
+	err = binary.Read(r, binary.LittleEndian, &bitmapCount)
+	if err != nil {
+		return fmt.Errorf("read bitmap count: %w", err)
+	}
+
+	if v.IsAnimated {
+		err = binary.Read(r, binary.LittleEndian, &v.FrameDelay)
+		if err != nil {
+			return fmt.Errorf("read frame delay: %w", err)
+		}
+	}
+
+	for i := uint32(0); i < bitmapCount; i++ {
+		var value uint32
+		err = binary.Read(r, binary.LittleEndian, &value)
+		if err != nil {
+			return fmt.Errorf("read %d bitmap name reference: %w", i, err)
+		}
+		v.BitmapNameReferences = append(v.BitmapNameReferences, value)
+	}
+	return nil
+}
+
+func (v *BitmapInfo) FragmentType() string {
+	return "Bitmap Info"
+}
+
+func (v *BitmapInfo) FragmentCode() int32 {
+	return 0x04
+}
+
+func (v *BitmapInfo) Name() string {
+	return v.name
+}
+
+func (v *BitmapInfo) SetName(name string) {
+	v.name = name
+}
+
+func (v *BitmapInfo) References() []uint32 {
+	return v.BitmapNameReferences
+}
+
+func (v *BitmapInfo) Write(w io.Writer) error {
+	flags := v.Flags &^ 0x08
+	if v.IsAnimated {
+		flags |= 0x08
+	}
+	err := binary.Write(w, binary.LittleEndian, flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(len(v.BitmapNameReferences)))
+	if err != nil {
+		return fmt.Errorf("write bitmap count: %w", err)
+	}
+
+	if v.IsAnimated {
+		err = binary.Write(w, binary.LittleEndian, v.FrameDelay)
+		if err != nil {
+			return fmt.Errorf("write frame delay: %w", err)
+		}
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.BitmapNameReferences)
+	if err != nil {
+		return fmt.Errorf("write bitmap name references: %w", err)
+	}
+	return nil
+}
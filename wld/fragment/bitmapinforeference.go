@@ -0,0 +1,79 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// BitmapInfoReference information, referenced by a material
+type BitmapInfoReference struct {
+	hashIndex uint32
+	name      string
+	// Reference points to a bitmap info fragment
+	Reference uint32
+	Flags     uint32
+}
+
+func LoadBitmapInfoReference(r io.ReadSeeker) (*BitmapInfoReference, error) {
+	v := &BitmapInfoReference{}
+	err := parseBitmapInfoReference(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse BitmapInfoReference: %w", err)
+	}
+	return v, nil
+}
+
+func parseBitmapInfoReference(r io.ReadSeeker, v *BitmapInfoReference) error {
+	if v == nil {
+		return fmt.Errorf("BitmapInfoReference is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Reference)
+	if err != nil {
+		return fmt.Errorf("read reference: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+	return nil
+}
+
+func (v *BitmapInfoReference) FragmentType() string {
+	return "Bitmap Info Reference"
+}
+
+func (v *BitmapInfoReference) FragmentCode() int32 {
+	return 0x05
+}
+
+func (v *BitmapInfoReference) Name() string {
+	return v.name
+}
+
+func (v *BitmapInfoReference) SetName(name string) {
+	v.name = name
+}
+
+func (v *BitmapInfoReference) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *BitmapInfoReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.Reference)
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.Flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+	return nil
+}
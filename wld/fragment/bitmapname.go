@@ -0,0 +1,100 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BitmapName information, the texture files of a bitmap info
+type BitmapName struct {
+	hashIndex uint32
+	name      string
+	// Files are the texture file names, such as sand.bmp, stored in the same archive
+	Files []string
+}
+
+func LoadBitmapName(r io.ReadSeeker) (*BitmapName, error) {
+	v := &BitmapName{}
+	err := parseBitmapName(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse BitmapName: %w", err)
+	}
+	return v, nil
+}
+
+func parseBitmapName(r io.ReadSeeker, v *BitmapName) error {
+	if v == nil {
+		return fmt.Errorf("BitmapName is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	// the count is stored as one less than the number of files
+	var fileCount uint32
+	err = binary.Read(r, binary.LittleEndian, &fileCount)
+	if err != nil {
+		return fmt.Errorf("read file count: %w", err)
+	}
+
+	for i := uint64(0); i < uint64(fileCount)+1; i++ {
+		var nameLength uint16
+		err = binary.Read(r, binary.LittleEndian, &nameLength)
+		if err != nil {
+			return fmt.Errorf("read name length %d: %w", i, err)
+		}
+		nameRaw := make([]byte, nameLength)
+		_, err = io.ReadFull(r, nameRaw)
+		if err != nil {
+			return fmt.Errorf("read name %d: %w", i, err)
+		}
+		v.Files = append(v.Files, strings.TrimRight(string(DecodeString(nameRaw)), "\x00"))
+	}
+	return nil
+}
+
+func (v *BitmapName) FragmentType() string {
+	return "Bitmap Name"
+}
+
+func (v *BitmapName) FragmentCode() int32 {
+	return 0x03
+}
+
+func (v *BitmapName) Name() string {
+	return v.name
+}
+
+func (v *BitmapName) SetName(name string) {
+	v.name = name
+}
+
+func (v *BitmapName) References() []uint32 {
+	return nil
+}
+
+func (v *BitmapName) Write(w io.Writer) error {
+	// the count is one less than the number of files, so there is no way to write none
+	if len(v.Files) == 0 {
+		return fmt.Errorf("no files")
+	}
+	err := binary.Write(w, binary.LittleEndian, uint32(len(v.Files)-1))
+	if err != nil {
+		return fmt.Errorf("write file count: %w", err)
+	}
+	for i, file := range v.Files {
+		nameRaw := DecodeString([]byte(file + "\x00"))
+		err = binary.Write(w, binary.LittleEndian, uint16(len(nameRaw)))
+		if err != nil {
+			return fmt.Errorf("write name length %d: %w", i, err)
+		}
+		_, err = w.Write(nameRaw)
+		if err != nil {
+			return fmt.Errorf("write name %d: %w", i, err)
+		}
+	}
+	return nil
+}
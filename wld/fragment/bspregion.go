@@ -1,17 +1,44 @@
 package fragment
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+
+	"github.com/g3n/engine/math32"
 )
 
-// BspRegion information
+// BspRegion information, a leaf of the bsp tree
 type BspRegion struct {
 	hashIndex   uint32
+	name        string
+	Flags       uint32
 	HasPolygons bool
-	Reference   uint32
-	RegionType  uint32
+	// Reference points to the mesh drawn in the region, only set if HasPolygons
+	Reference  uint32
+	RegionType uint32
+	// AmbientLightReference points to an ambient light fragment, usually 0
+	AmbientLightReference uint32
+	Sphere                BspSphere
+	// PVS is the run length encoded potentially visible set, one entry per visibility list
+	PVS [][]byte
+	// VisibleRegions are the region indices, counting from 0, that can be seen from this region.
+	// They are only decoded from a pvs of bytes, a pvs of words (flag 0x20) leaves them nil
+	VisibleRegions []int
+	// sizes are data1, data2, unknown2, data3, data4, unknown3 and data5 as decoded,
+	// data holds the sections they count, kept so they encode unchanged
+	sizes    [7]uint32
+	data     []byte
+	reverb   [2]uint32
+	userData []byte
+}
+
+// BspSphere bounds a region, set when bit 0 of the region flags is set
+type BspSphere struct {
+	Center math32.Vector3
+	Radius float32
 }
 
 func LoadBspRegion(r io.ReadSeeker) (*BspRegion, error) {
@@ -27,138 +54,286 @@ func parseBspRegion(r io.ReadSeeker, v *BspRegion) error {
 	if v == nil {
 		return fmt.Errorf("bsp region is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
 	// Flags
 	// 0x181 - Regions with polygons
 	// 0x81 - Regions without
+	// Bit 0 - has a sphere
 	// Bit 5 - PVS is WORDS
 	// Bit 7 - PVS is bytes
-	if value == 0x181 {
-		v.HasPolygons = true
-	}
+	// Bit 8 - has a mesh reference
+	v.HasPolygons = v.Flags&0x100 != 0
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.AmbientLightReference)
 	if err != nil {
-		return fmt.Errorf("read unknown1: %w", err)
+		return fmt.Errorf("read ambient light reference: %w", err)
 	}
 
-	var data1Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data1Size)
+	err = binary.Read(r, binary.LittleEndian, &v.sizes)
 	if err != nil {
-		return fmt.Errorf("read data1size: %w", err)
+		return fmt.Errorf("read sizes: %w", err)
 	}
+	data1Size, data2Size, data3Size, data5Size := v.sizes[0], v.sizes[1], v.sizes[3], v.sizes[6]
 
-	var data2Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data2Size)
+	var data6Size uint32
+	err = binary.Read(r, binary.LittleEndian, &data6Size)
 	if err != nil {
-		return fmt.Errorf("read data2size: %w", err)
+		return fmt.Errorf("read data6size: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	v.data, err = readBytes(r, nil, int64(data1Size)*12+int64(data2Size)*12)
 	if err != nil {
-		return fmt.Errorf("read unknown2: %w", err)
+		return fmt.Errorf("read data1 and data2: %w", err)
 	}
 
-	var data3Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data3Size)
-	if err != nil {
-		return fmt.Errorf("read data3size: %w", err)
+	for i := 0; i < int(data3Size); i++ {
+		v.data, err = readBytes(r, v.data, 8)
+		if err != nil {
+			return fmt.Errorf("read data3flags (%d): %w", i, err)
+		}
+		count := binary.LittleEndian.Uint32(v.data[len(v.data)-4:])
+		v.data, err = readBytes(r, v.data, int64(count)*4)
+		if err != nil {
+			return fmt.Errorf("read data3 (%d): %w", i, err)
+		}
 	}
 
-	var data4Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data4Size)
+	//TODO: move past data 4? skipped?
+
+	v.data, err = readBytes(r, v.data, int64(data5Size)*7*4)
 	if err != nil {
-		return fmt.Errorf("read data4size: %w", err)
+		return fmt.Errorf("read data5: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
-	if err != nil {
-		return fmt.Errorf("read unknown3: %w", err)
+	for i := 0; i < int(data6Size); i++ {
+		var pvsSize uint16
+		err = binary.Read(r, binary.LittleEndian, &pvsSize)
+		if err != nil {
+			return fmt.Errorf("read pvs size %d: %w", i, err)
+		}
+		size := int(pvsSize)
+		if v.Flags&0x20 != 0 {
+			size *= 2
+		}
+		pvs := make([]byte, size)
+		_, err = io.ReadFull(r, pvs)
+		if err != nil {
+			return fmt.Errorf("read pvs %d: %w", i, err)
+		}
+		v.PVS = append(v.PVS, pvs)
+	}
+	if len(v.PVS) > 0 && v.Flags&0x20 == 0 {
+		v.VisibleRegions, err = decodePVS(v.PVS[0])
+		if err != nil {
+			return fmt.Errorf("decode pvs: %w", err)
+		}
 	}
 
-	var data5Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data5Size)
-	if err != nil {
-		return fmt.Errorf("read data1size: %w", err)
+	if v.Flags&0x1 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.Sphere)
+		if err != nil {
+			return fmt.Errorf("read sphere: %w", err)
+		}
 	}
 
-	var data6Size uint32
-	err = binary.Read(r, binary.LittleEndian, &data6Size)
-	if err != nil {
-		return fmt.Errorf("read data2size: %w", err)
+	// bit 1 has a reverb volume, bit 2 a reverb offset
+	if v.Flags&0x2 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.reverb[0])
+		if err != nil {
+			return fmt.Errorf("read reverb volume: %w", err)
+		}
+	}
+	if v.Flags&0x4 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.reverb[1])
+		if err != nil {
+			return fmt.Errorf("read reverb offset: %w", err)
+		}
 	}
 
-	_, err = r.Seek(int64(12*data1Size+12*data2Size), io.SeekCurrent)
+	var userDataSize uint32
+	err = binary.Read(r, binary.LittleEndian, &userDataSize)
 	if err != nil {
-		return fmt.Errorf("seek past data1size: %w", err)
+		return fmt.Errorf("read user data size: %w", err)
+	}
+	v.userData, err = readBytes(r, nil, int64(userDataSize))
+	if err != nil {
+		return fmt.Errorf("read user data: %w", err)
 	}
 
-	for i := 0; i < int(data3Size); i++ {
-		err = binary.Read(r, binary.LittleEndian, &value)
+	if v.HasPolygons {
+		err = binary.Read(r, binary.LittleEndian, &v.Reference)
 		if err != nil {
-			return fmt.Errorf("read data3flags (%d): %w", i, err)
+			return fmt.Errorf("read mesh reference: %w", err)
 		}
+	}
 
-		err = binary.Read(r, binary.LittleEndian, &value)
-		if err != nil {
-			return fmt.Errorf("read data3flags seek (%d): %w", i, err)
+	return nil
+}
+
+// readBytes appends size bytes read from r to data, growing only as bytes arrive
+// so a corrupt size can't allocate more than the reader holds
+func readBytes(r io.Reader, data []byte, size int64) ([]byte, error) {
+	buf := bytes.NewBuffer(data)
+	_, err := io.CopyN(buf, r, size)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return data, err
+	}
+	return buf.Bytes(), nil
+}
 
-		_, err = r.Seek(int64(value*4), io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("seek past data3flags (%d): %w", i, err)
+// decodePVS expands a byte run length encoded visibility list into region indices.
+// Runs alternate between regions skipped and regions included, starting at region 0
+func decodePVS(data []byte) ([]int, error) {
+	regions := []int{}
+	region := 0
+	include := func(count int) {
+		for i := 0; i < count; i++ {
+			regions = append(regions, region)
+			region++
+		}
+	}
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x3F:
+			region += int(b)
+		case b == 0x3F, b == 0xFF:
+			if i+2 >= len(data) {
+				return nil, fmt.Errorf("run at %d is truncated", i)
+			}
+			count := int(binary.LittleEndian.Uint16(data[i+1:]))
+			i += 2
+			if b == 0x3F {
+				region += count
+				continue
+			}
+			include(count)
+		case b < 0x80:
+			region += int(b>>3) & 0x7
+			include(int(b) & 0x7)
+		case b < 0xC0:
+			include(int(b>>3) & 0x7)
+			region += int(b) & 0x7
+		default:
+			include(int(b) - 0xC0)
 		}
 	}
+	return regions, nil
+}
 
-	//TODO: move past data 4? skipped?
+func (v *BspRegion) FragmentType() string {
+	return "BSP Region"
+}
 
-	for i := 0; i < int(data5Size); i++ {
-		_, err = r.Seek(int64(7*4), io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("seek past data5size: %w", err)
-		}
+func (v *BspRegion) FragmentCode() int32 {
+	return 0x22
+}
+
+func (v *BspRegion) Name() string {
+	return v.name
+}
+
+func (v *BspRegion) SetName(name string) {
+	v.name = name
+}
+
+func (v *BspRegion) References() []uint32 {
+	if !v.HasPolygons {
+		return references(v.AmbientLightReference)
 	}
+	return references(v.AmbientLightReference, v.Reference)
+}
 
-	var pvsSize uint16
-	err = binary.Read(r, binary.LittleEndian, &pvsSize)
+// Write writes the region, data sections, reverb and user data are written as decoded
+func (v *BspRegion) Write(w io.Writer) error {
+	flags := v.Flags &^ 0x100
+	if v.HasPolygons {
+		flags |= 0x100
+	}
+	err := binary.Write(w, binary.LittleEndian, []uint32{flags, v.AmbientLightReference})
 	if err != nil {
-		return fmt.Errorf("read pvsSize: %w", err)
+		return fmt.Errorf("write flags: %w", err)
 	}
 
-	_, err = r.Seek(int64(pvsSize), io.SeekCurrent)
+	// data4 is not read on decode, so it can't be written back
+	if v.sizes[4] > 0 {
+		return fmt.Errorf("data4 size %d is not supported", v.sizes[4])
+	}
+	err = binary.Write(w, binary.LittleEndian, append(v.sizes[:], uint32(len(v.PVS))))
 	if err != nil {
-		return fmt.Errorf("seek pvsSize: %w", err)
+		return fmt.Errorf("write sizes: %w", err)
 	}
-
-	err = binary.Read(r, binary.LittleEndian, &value)
+	_, err = w.Write(v.data)
 	if err != nil {
-		return fmt.Errorf("read bytes: %w", err)
+		return fmt.Errorf("write data: %w", err)
 	}
 
-	_, err = r.Seek(16, io.SeekCurrent)
+	for i, pvs := range v.PVS {
+		size := len(pvs)
+		if flags&0x20 != 0 {
+			if size%2 != 0 {
+				return fmt.Errorf("pvs %d of words is %d bytes", i, size)
+			}
+			size /= 2
+		}
+		if size > math.MaxUint16 {
+			return fmt.Errorf("pvs %d is %d long", i, size)
+		}
+		err = binary.Write(w, binary.LittleEndian, uint16(size))
+		if err != nil {
+			return fmt.Errorf("write pvs size %d: %w", i, err)
+		}
+		_, err = w.Write(pvs)
+		if err != nil {
+			return fmt.Errorf("write pvs %d: %w", i, err)
+		}
+	}
+
+	if flags&0x1 != 0 {
+		err = binary.Write(w, binary.LittleEndian, &v.Sphere)
+		if err != nil {
+			return fmt.Errorf("write sphere: %w", err)
+		}
+	}
+
+	if flags&0x2 != 0 {
+		err = binary.Write(w, binary.LittleEndian, v.reverb[0])
+		if err != nil {
+			return fmt.Errorf("write reverb volume: %w", err)
+		}
+	}
+	if flags&0x4 != 0 {
+		err = binary.Write(w, binary.LittleEndian, v.reverb[1])
+		if err != nil {
+			return fmt.Errorf("write reverb offset: %w", err)
+		}
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(len(v.userData)))
 	if err != nil {
-		return fmt.Errorf("seek unknown: %w", err)
+		return fmt.Errorf("write user data size: %w", err)
+	}
+	_, err = w.Write(v.userData)
+	if err != nil {
+		return fmt.Errorf("write user data: %w", err)
 	}
 
 	if v.HasPolygons {
-		err = binary.Read(r, binary.LittleEndian, &v.Reference)
+		err = binary.Write(w, binary.LittleEndian, v.Reference)
 		if err != nil {
-			return fmt.Errorf("read mesh reference: %w", err)
+			return fmt.Errorf("write mesh reference: %w", err)
 		}
 	}
-
 	return nil
 }
-
-func (v *BspRegion) FragmentType() string {
-	return "BSP Region"
-}
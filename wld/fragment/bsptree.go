@@ -0,0 +1,121 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/g3n/engine/math32"
+)
+
+// BspTree information, the world tree splitting a zone into regions
+type BspTree struct {
+	hashIndex uint32
+	name      string
+	Nodes     []*BspNode
+}
+
+// BspNode is a splitting plane of a bsp tree, or a leaf if RegionID is set
+type BspNode struct {
+	Normal        math32.Vector3
+	SplitDistance float32
+	// RegionID is the region a leaf holds, counting from 1, 0 if not a leaf
+	RegionID uint32
+	// Front and Back are node indices counting from 1, 0 if there is no node
+	Front uint32
+	Back  uint32
+}
+
+func LoadBspTree(r io.ReadSeeker) (*BspTree, error) {
+	v := &BspTree{}
+	err := parseBspTree(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse bsp tree: %w", err)
+	}
+	return v, nil
+}
+
+func parseBspTree(r io.ReadSeeker, v *BspTree) error {
+	if v == nil {
+		return fmt.Errorf("bsp tree is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	var nodeCount uint32
+	err = binary.Read(r, binary.LittleEndian, &nodeCount)
+	if err != nil {
+		return fmt.Errorf("read node count: %w", err)
+	}
+
+	for i := uint32(0); i < nodeCount; i++ {
+		node := &BspNode{}
+		err = binary.Read(r, binary.LittleEndian, node)
+		if err != nil {
+			return fmt.Errorf("read node %d: %w", i, err)
+		}
+		v.Nodes = append(v.Nodes, node)
+	}
+	return nil
+}
+
+// RegionAt walks the tree to the leaf holding point, returning its region id counting from 1, or 0 if there is none
+func (v *BspTree) RegionAt(point math32.Vector3) uint32 {
+	if len(v.Nodes) == 0 {
+		return 0
+	}
+	index := uint32(1)
+	// a malformed tree can loop, but a walk never visits more nodes than there are
+	for steps := 0; steps <= len(v.Nodes); steps++ {
+		if index == 0 || int(index) > len(v.Nodes) {
+			return 0
+		}
+		node := v.Nodes[index-1]
+		if node.RegionID != 0 {
+			return node.RegionID
+		}
+		if node.Normal.Dot(&point)+node.SplitDistance > 0 {
+			index = node.Front
+			continue
+		}
+		index = node.Back
+	}
+	return 0
+}
+
+func (v *BspTree) FragmentType() string {
+	return "BSP Tree"
+}
+
+func (v *BspTree) FragmentCode() int32 {
+	return 0x21
+}
+
+func (v *BspTree) Name() string {
+	return v.name
+}
+
+func (v *BspTree) SetName(name string) {
+	v.name = name
+}
+
+func (v *BspTree) References() []uint32 {
+	return nil
+}
+
+func (v *BspTree) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, uint32(len(v.Nodes)))
+	if err != nil {
+		return fmt.Errorf("write node count: %w", err)
+	}
+
+	for i, node := range v.Nodes {
+		err = binary.Write(w, binary.LittleEndian, node)
+		if err != nil {
+			return fmt.Errorf("write node %d: %w", i, err)
+		}
+	}
+	return nil
+}
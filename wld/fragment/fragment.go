@@ -1,7 +1,28 @@
 package fragment
 
+import "io"
+
 // Fragment is what every fragment object type adheres to
 type Fragment interface {
 	// FragmentType identifies the fragment type
 	FragmentType() string
+	// FragmentCode is the type code the fragment is stored with, such as 0x36 for a mesh
+	FragmentCode() int32
+	// Name is resolved from the string hash when the wld is decoded
+	Name() string
+	// References are the fragments this fragment points to, counting from 1
+	References() []uint32
+	// Write writes the fragment after its name reference, which the wld writes as it owns the string hash
+	Write(w io.Writer) error
+}
+
+// references drops the references that are 0, which point to no fragment
+func references(refs ...uint32) []uint32 {
+	var out []uint32
+	for _, ref := range refs {
+		if ref != 0 {
+			out = append(out, ref)
+		}
+	}
+	return out
 }
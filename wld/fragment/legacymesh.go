@@ -11,6 +11,7 @@ import (
 // LegacyMesh information
 type LegacyMesh struct {
 	HashIndex         uint32
+	name              string
 	Flags             uint32
 	VertexCount       uint32
 	TexCoordCount     uint32
@@ -208,3 +209,23 @@ func parseLegacyMesh(r io.ReadSeeker, l *LegacyMesh) error {
 func (l *LegacyMesh) FragmentType() string {
 	return "Legacy Mesh"
 }
+
+func (l *LegacyMesh) FragmentCode() int32 {
+	return 0x2C
+}
+
+func (l *LegacyMesh) Name() string {
+	return l.name
+}
+
+func (l *LegacyMesh) SetName(name string) {
+	l.name = name
+}
+
+func (l *LegacyMesh) References() []uint32 {
+	return references(l.MaterialReference)
+}
+
+func (l *LegacyMesh) Write(w io.Writer) error {
+	return fmt.Errorf("legacy mesh is not supported")
+}
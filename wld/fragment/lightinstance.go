@@ -11,7 +11,9 @@ import (
 // LightInstance information
 type LightInstance struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
+	Flags     uint32
 	Position  math32.Vector3
 	Radius    float32
 }
@@ -29,7 +31,6 @@ func parseLightInstance(r io.ReadSeeker, l *LightInstance) error {
 	if l == nil {
 		return fmt.Errorf("light instance is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &l.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
@@ -40,7 +41,7 @@ func parseLightInstance(r io.ReadSeeker, l *LightInstance) error {
 		return fmt.Errorf("read reference: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &l.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
@@ -71,3 +72,32 @@ func parseLightInstance(r io.ReadSeeker, l *LightInstance) error {
 func (l *LightInstance) FragmentType() string {
 	return "Light Instance"
 }
+
+func (l *LightInstance) FragmentCode() int32 {
+	return 0x28
+}
+
+func (l *LightInstance) Name() string {
+	return l.name
+}
+
+func (l *LightInstance) SetName(name string) {
+	l.name = name
+}
+
+func (l *LightInstance) References() []uint32 {
+	return references(l.Reference)
+}
+
+func (l *LightInstance) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, []uint32{l.Reference, l.Flags})
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, []float32{l.Position.X, l.Position.Y, l.Position.Z, l.Radius})
+	if err != nil {
+		return fmt.Errorf("write position: %w", err)
+	}
+	return nil
+}
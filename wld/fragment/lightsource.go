@@ -18,6 +18,8 @@ type LightSource struct {
 	//Attenuation (?) - guess from Windcatcher. Not sure what it is.
 	Attentuation uint32
 	hashIndex    uint32
+	name         string
+	Flags        uint32
 }
 
 func LoadLightSource(r io.ReadSeeker) (*LightSource, error) {
@@ -39,15 +41,15 @@ func parseLightSource(r io.ReadSeeker, l *LightSource) error {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &l.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
 
-	if value&1 == 1 {
+	if l.Flags&1 == 1 {
 		l.IsPlacedLightSource = true
 	}
-	if value&4 == 4 {
+	if l.Flags&4 == 4 {
 		l.IsColoredLight = true
 	}
 
@@ -101,3 +103,63 @@ func parseLightSource(r io.ReadSeeker, l *LightSource) error {
 func (l *LightSource) FragmentType() string {
 	return "Light Source"
 }
+
+func (l *LightSource) FragmentCode() int32 {
+	return 0x1B
+}
+
+func (l *LightSource) Name() string {
+	return l.name
+}
+
+func (l *LightSource) SetName(name string) {
+	l.name = name
+}
+
+func (l *LightSource) References() []uint32 {
+	return nil
+}
+
+func (l *LightSource) Write(w io.Writer) error {
+	flags := l.Flags &^ 0x5
+	if l.IsPlacedLightSource {
+		flags |= 1
+	}
+	if l.IsColoredLight {
+		flags |= 4
+	}
+	err := binary.Write(w, binary.LittleEndian, flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	// unknowns are not kept, and written as 0
+	if !l.IsPlacedLightSource {
+		err = binary.Write(w, binary.LittleEndian, []uint32{0, 0})
+		if err != nil {
+			return fmt.Errorf("write unknowns: %w", err)
+		}
+		return nil
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(0))
+	if err != nil {
+		return fmt.Errorf("write unknown1: %w", err)
+	}
+	if l.IsColoredLight {
+		err = binary.Write(w, binary.LittleEndian, l.Attentuation)
+		if err != nil {
+			return fmt.Errorf("write attentuation: %w", err)
+		}
+		err = binary.Write(w, binary.LittleEndian, []uint8{l.Color.A, l.Color.R, l.Color.G, l.Color.B})
+		if err != nil {
+			return fmt.Errorf("write color: %w", err)
+		}
+		return nil
+	}
+	err = binary.Write(w, binary.LittleEndian, uint32(0))
+	if err != nil {
+		return fmt.Errorf("write unknown noncolored: %w", err)
+	}
+	return nil
+}
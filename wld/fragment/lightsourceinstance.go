@@ -11,6 +11,7 @@ import (
 // LightSourceInstance information
 type LightSourceInstance struct {
 	HashIndex uint32
+	name      string
 	Flags     uint32
 	Reference uint32
 	Position  math32.Vector3
@@ -40,3 +41,11 @@ func parseLightSourceInstance(r io.ReadSeeker, l *LightSourceInstance) error {
 func (l *LightSourceInstance) FragmentType() string {
 	return "Light Source Instance"
 }
+
+func (l *LightSourceInstance) Name() string {
+	return l.name
+}
+
+func (l *LightSourceInstance) SetName(name string) {
+	l.name = name
+}
@@ -9,6 +9,7 @@ import (
 // LightSourceReference information
 type LightSourceReference struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
 }
 
@@ -41,3 +42,27 @@ func parseLightSourceReference(r io.ReadSeeker, l *LightSourceReference) error {
 func (l *LightSourceReference) FragmentType() string {
 	return "Light Source Reference"
 }
+
+func (l *LightSourceReference) FragmentCode() int32 {
+	return 0x1C
+}
+
+func (l *LightSourceReference) Name() string {
+	return l.name
+}
+
+func (l *LightSourceReference) SetName(name string) {
+	l.name = name
+}
+
+func (l *LightSourceReference) References() []uint32 {
+	return references(l.Reference)
+}
+
+func (l *LightSourceReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, l.Reference)
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+	return nil
+}
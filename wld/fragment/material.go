@@ -9,12 +9,20 @@ import (
 
 // Material information
 type Material struct {
-	//BitmapInfoReference
+	// BitmapInfoReference points to the bitmap info reference fragment with the textures, 0 if there are none
+	BitmapInfoReference uint32
+	Color               color.RGBA
+	Brightness          float32
+	ScaledAmbient       float32
 	// ShaderType is the way to render the material
 	ShaderType int
 	// MaterialType is also part of rendering material
 	MaterialType int
-	hashIndex    uint32
+	// params is the stored word MaterialType is read from, its high bit is kept when written
+	params    uint32
+	hashIndex uint32
+	name      string
+	Flags     uint32
 	// IsHandled is used when an alternative character skin is needed
 	IsHandled bool
 }
@@ -32,62 +40,53 @@ func parseMaterial(r io.ReadSeeker, m *Material) error {
 	if m == nil {
 		return fmt.Errorf("Material is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &m.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &m.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
-	//TODO: flags support
-	var params int32
-	err = binary.Read(r, binary.LittleEndian, &params)
+	err = binary.Read(r, binary.LittleEndian, &m.params)
 	if err != nil {
 		return fmt.Errorf("read params: %w", err)
 	}
 
-	//TODO: figure out color
-	rgba := color.RGBA{}
-	err = binary.Read(r, binary.LittleEndian, &rgba.R)
+	err = binary.Read(r, binary.LittleEndian, &m.Color.R)
 	if err != nil {
 		return fmt.Errorf("read color red: %w", err)
 	}
-	err = binary.Read(r, binary.LittleEndian, &rgba.G)
+	err = binary.Read(r, binary.LittleEndian, &m.Color.G)
 	if err != nil {
 		return fmt.Errorf("read color green: %w", err)
 	}
-	err = binary.Read(r, binary.LittleEndian, &rgba.B)
+	err = binary.Read(r, binary.LittleEndian, &m.Color.B)
 	if err != nil {
 		return fmt.Errorf("read color blue: %w", err)
 	}
-	err = binary.Read(r, binary.LittleEndian, &rgba.A)
+	err = binary.Read(r, binary.LittleEndian, &m.Color.A)
 	if err != nil {
 		return fmt.Errorf("read color alpha: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &rgba.A)
+	err = binary.Read(r, binary.LittleEndian, &m.Brightness)
 	if err != nil {
-		return fmt.Errorf("read unknown float 1: %w", err)
+		return fmt.Errorf("read brightness: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &rgba.A)
+	err = binary.Read(r, binary.LittleEndian, &m.ScaledAmbient)
 	if err != nil {
-		return fmt.Errorf("read unknown float 2: %w", err)
+		return fmt.Errorf("read scaled ambient: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &m.BitmapInfoReference)
 	if err != nil {
-		return fmt.Errorf("read fragment reference: %w", err)
+		return fmt.Errorf("read bitmap info reference: %w", err)
 	}
-	// TODO: add bitmapinforeference support
-	//if value != 0 {
-	//BitmapInfoReference = fragments[fragmentReference - 1] as BitmapInfoReference;
-	//}
 
-	m.MaterialType = int(int64(params) & ^0x80000000)
+	m.MaterialType = int(m.params &^ 0x80000000)
 	switch m.MaterialType {
 	case MaterialTypeBoundary:
 		m.ShaderType = ShaderTypeBoundary
@@ -114,7 +113,10 @@ func parseMaterial(r io.ReadSeeker, m *Material) error {
 	case MaterialTypeTransparentAdditiveUnlitSkydome:
 		m.ShaderType = ShaderTypeTransparentAdditiveUnlitSkydome
 	default:
-		//m.ShaderType = BitmapInfoReference == null ? ShaderTypeInvisible : ShaderTypeDiffuse;
+		m.ShaderType = ShaderTypeDiffuse
+		if m.BitmapInfoReference == 0 {
+			m.ShaderType = ShaderTypeInvisible
+		}
 	}
 	return nil
 }
@@ -122,3 +124,47 @@ func parseMaterial(r io.ReadSeeker, m *Material) error {
 func (m *Material) FragmentType() string {
 	return "Material"
 }
+
+func (m *Material) FragmentCode() int32 {
+	return 0x30
+}
+
+func (m *Material) Name() string {
+	return m.name
+}
+
+func (m *Material) SetName(name string) {
+	m.name = name
+}
+
+func (m *Material) References() []uint32 {
+	return references(m.BitmapInfoReference)
+}
+
+func (m *Material) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, m.Flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(m.MaterialType)|m.params&0x80000000)
+	if err != nil {
+		return fmt.Errorf("write params: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, []uint8{m.Color.R, m.Color.G, m.Color.B, m.Color.A})
+	if err != nil {
+		return fmt.Errorf("write color: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, []float32{m.Brightness, m.ScaledAmbient})
+	if err != nil {
+		return fmt.Errorf("write brightness: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, m.BitmapInfoReference)
+	if err != nil {
+		return fmt.Errorf("write bitmap info reference: %w", err)
+	}
+	return nil
+}
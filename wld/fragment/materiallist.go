@@ -9,6 +9,8 @@ import (
 // MaterialList information
 type MaterialList struct {
 	hashIndex          uint32
+	name               string
+	Flags              uint32
 	MaterialReferences []uint32
 }
 
@@ -31,13 +33,11 @@ func parseMaterialList(r io.ReadSeeker, m *MaterialList) error {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &m.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
 
-	//TODO: flags support
-
 	var materialCount uint32
 	err = binary.Read(r, binary.LittleEndian, &materialCount)
 	if err != nil {
@@ -57,3 +57,37 @@ func parseMaterialList(r io.ReadSeeker, m *MaterialList) error {
 func (m *MaterialList) FragmentType() string {
 	return "Material List"
 }
+
+func (m *MaterialList) FragmentCode() int32 {
+	return 0x31
+}
+
+func (m *MaterialList) Name() string {
+	return m.name
+}
+
+func (m *MaterialList) SetName(name string) {
+	m.name = name
+}
+
+func (m *MaterialList) References() []uint32 {
+	return m.MaterialReferences
+}
+
+func (m *MaterialList) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, m.Flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(len(m.MaterialReferences)))
+	if err != nil {
+		return fmt.Errorf("write material count: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, m.MaterialReferences)
+	if err != nil {
+		return fmt.Errorf("write material references: %w", err)
+	}
+	return nil
+}
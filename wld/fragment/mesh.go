@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"image/color"
 	"io"
+	"math"
 
 	"github.com/g3n/engine/math32"
 )
 
 // Mesh information
 type Mesh struct {
-	hashIndex            uint32
-	MaterialReference    uint32
-	AnimationReference   uint32
-	Center               math32.Vector3
-	MaxDistance          float32
+	hashIndex uint32
+	name      string
+	// Flags are 0x00018003 for zone meshes and 0x00014003 for object meshes, 0 writes a zone mesh
+	Flags uint32
+	// IsNewWorldFormat stores uvs as 32 bit instead of 16 bit
+	IsNewWorldFormat   bool
+	MaterialReference  uint32
+	AnimationReference uint32
+	Center             math32.Vector3
+	MaxDistance        float32
+	// Scale is the shift vertices are stored with, as integers divided by 1<<Scale
+	Scale                int
 	MinPosition          math32.Vector3
 	MaxPosition          math32.Vector3
 	Verticies            []math32.Vector3
@@ -23,10 +31,42 @@ type Mesh struct {
 	Normals              []math32.Vector3
 	Colors               []color.RGBA
 	Indices              []*Polygon
+	// VertexPieces groups vertices by the skeleton bone they move with
+	VertexPieces []*MeshVertexPiece
+	// RenderGroups are runs of polygons that share a material, in polygon order
+	RenderGroups []*MeshRenderGroup
+	// VertexTextures are runs of vertices that share a material, in vertex order
+	VertexTextures []*MeshVertexTexture
+	// unknowns are the two values after AnimationReference, centerUnknowns the three after Center
+	unknowns       [2]uint32
+	centerUnknowns [3]uint32
+	// size9 are the unknown 12 byte entries after the vertex textures, kept as stored
+	size9 []byte
+	// paddedUVs is how many uvs were added when decoding so every vertex has one, they are not written
+	paddedUVs int
+}
+
+// MeshVertexPiece is a run of vertices attached to a bone
+type MeshVertexPiece struct {
+	Start int
+	Count int
+	Bone  int
+}
+
+// MeshRenderGroup is a run of polygons drawn with one material of the material list
+type MeshRenderGroup struct {
+	PolygonCount  int
+	MaterialIndex int
+}
+
+// MeshVertexTexture is a run of vertices using one material of the material list
+type MeshVertexTexture struct {
+	VertexCount   int
+	MaterialIndex int
 }
 
 func LoadMesh(r io.ReadSeeker, isNewWorldFormat bool) (*Mesh, error) {
-	v := &Mesh{}
+	v := &Mesh{IsNewWorldFormat: isNewWorldFormat}
 	err := parseMesh(r, v, isNewWorldFormat)
 	if err != nil {
 		return nil, fmt.Errorf("parse Mesh: %w", err)
@@ -38,19 +78,18 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 	if v == nil {
 		return fmt.Errorf("mesh is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
 
-	if value != 0x00018003 && value != 0x00014003 {
-		return fmt.Errorf("unknown mesh type, got 0x%x", value)
+	if v.Flags != 0x00018003 && v.Flags != 0x00014003 {
+		return fmt.Errorf("unknown mesh type, got 0x%x", v.Flags)
 	}
 
 	err = binary.Read(r, binary.LittleEndian, &v.MaterialReference)
@@ -63,14 +102,9 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 		return fmt.Errorf("read animation reference: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.unknowns)
 	if err != nil {
-		return fmt.Errorf("read unknown: %w", err)
-	}
-
-	err = binary.Read(r, binary.LittleEndian, &value)
-	if err != nil {
-		return fmt.Errorf("read unknown2: %w", err)
+		return fmt.Errorf("read unknowns: %w", err)
 	}
 
 	err = binary.Read(r, binary.LittleEndian, &v.Center.X)
@@ -88,19 +122,9 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 		return fmt.Errorf("read center z: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.centerUnknowns)
 	if err != nil {
-		return fmt.Errorf("read unknown1: %w", err)
-	}
-
-	err = binary.Read(r, binary.LittleEndian, &value)
-	if err != nil {
-		return fmt.Errorf("read unknown2: %w", err)
-	}
-
-	err = binary.Read(r, binary.LittleEndian, &value)
-	if err != nil {
-		return fmt.Errorf("read unknown3: %w", err)
+		return fmt.Errorf("read center unknowns: %w", err)
 	}
 
 	err = binary.Read(r, binary.LittleEndian, &v.MaxDistance)
@@ -108,21 +132,6 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 		return fmt.Errorf("read max distance: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &v.Center.X)
-	if err != nil {
-		return fmt.Errorf("read center x: %w", err)
-	}
-
-	err = binary.Read(r, binary.LittleEndian, &v.Center.Y)
-	if err != nil {
-		return fmt.Errorf("read center y: %w", err)
-	}
-
-	err = binary.Read(r, binary.LittleEndian, &v.Center.Z)
-	if err != nil {
-		return fmt.Errorf("read center z: %w", err)
-	}
-
 	err = binary.Read(r, binary.LittleEndian, &v.MinPosition.X)
 	if err != nil {
 		return fmt.Errorf("read min position x: %w", err)
@@ -207,96 +216,69 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 		return fmt.Errorf("read size9: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	var scaleShift int16
+	err = binary.Read(r, binary.LittleEndian, &scaleShift)
 	if err != nil {
-		return fmt.Errorf("read unknown2: %w", err)
+		return fmt.Errorf("read scale: %w", err)
 	}
 
-	scale := float32(1 / float32(int(1)<<value))
-
-	for i := 0; i < int(vertexCount); i++ {
-		pos := math32.Vector3{}
-		err = binary.Read(r, binary.LittleEndian, &pos.X)
-		if err != nil {
-			return fmt.Errorf("read vertex x %d: %w", i, err)
-		}
+	if scaleShift < 0 || scaleShift > 63 {
+		return fmt.Errorf("scale %d is out of range", scaleShift)
+	}
+	v.Scale = int(scaleShift)
 
-		err = binary.Read(r, binary.LittleEndian, &pos.Y)
-		if err != nil {
-			return fmt.Errorf("read vertex y %d: %w", i, err)
-		}
+	// vertices are stored as fixed point, scaled down by 1/(1<<scale)
+	scale := float32(1) / float32(uint64(1)<<uint16(scaleShift))
 
-		err = binary.Read(r, binary.LittleEndian, &pos.Z)
+	for i := 0; i < int(vertexCount); i++ {
+		var pos [3]int16
+		err = binary.Read(r, binary.LittleEndian, &pos)
 		if err != nil {
-			return fmt.Errorf("read vertex z %d: %w", i, err)
+			return fmt.Errorf("read vertex %d: %w", i, err)
 		}
-		pos.X *= scale
-		pos.Y *= scale
-		pos.Z *= scale
-
-		v.Verticies = append(v.Verticies, pos)
+		v.Verticies = append(v.Verticies, math32.Vector3{
+			X: float32(pos[0]) * scale,
+			Y: float32(pos[1]) * scale,
+			Z: float32(pos[2]) * scale,
+		})
 	}
 
 	for i := 0; i < int(textureCoordinateCount); i++ {
 		if isNewWorldFormat {
-			pos := math32.Vector2{}
-			err = binary.Read(r, binary.LittleEndian, &pos.X)
-			if err != nil {
-				return fmt.Errorf("read texture coordinate 32 x %d: %w", i, err)
-			}
-
-			err = binary.Read(r, binary.LittleEndian, &pos.Y)
+			var pos [2]int32
+			err = binary.Read(r, binary.LittleEndian, &pos)
 			if err != nil {
-				return fmt.Errorf("read texture coordinate 32 y %d: %w", i, err)
+				return fmt.Errorf("read texture coordinate 32 %d: %w", i, err)
 			}
-			pos.X /= 256
-			pos.Y /= 256
-			v.TextureUVCoordinates = append(v.TextureUVCoordinates, pos)
-
+			v.TextureUVCoordinates = append(v.TextureUVCoordinates, math32.Vector2{
+				X: float32(pos[0]) / 256,
+				Y: float32(pos[1]) / 256,
+			})
 			continue
 		}
 
-		var tmpPos int16
-		pos := math32.Vector2{}
-		err = binary.Read(r, binary.LittleEndian, &tmpPos)
-		if err != nil {
-			return fmt.Errorf("read texture coordinate 16 x %d: %w", i, err)
-		}
-
-		pos.X = float32(tmpPos)
-
-		err = binary.Read(r, binary.LittleEndian, &tmpPos)
+		var pos [2]int16
+		err = binary.Read(r, binary.LittleEndian, &pos)
 		if err != nil {
-			return fmt.Errorf("read texture coordinate 16 y %d: %w", i, err)
+			return fmt.Errorf("read texture coordinate 16 %d: %w", i, err)
 		}
-		pos.Y = float32(tmpPos)
-		pos.X /= 256
-		pos.Y /= 256
-		v.TextureUVCoordinates = append(v.TextureUVCoordinates, pos)
+		v.TextureUVCoordinates = append(v.TextureUVCoordinates, math32.Vector2{
+			X: float32(pos[0]) / 256,
+			Y: float32(pos[1]) / 256,
+		})
 	}
 
 	for i := 0; i < int(normalsCount); i++ {
-		var val uint8
-		pos := math32.Vector3{}
+		var val [3]int8
 		err = binary.Read(r, binary.LittleEndian, &val)
 		if err != nil {
-			return fmt.Errorf("read normals x %d: %w", i, err)
+			return fmt.Errorf("read normal %d: %w", i, err)
 		}
-		pos.X = float32(val / 128)
-
-		err = binary.Read(r, binary.LittleEndian, &val)
-		if err != nil {
-			return fmt.Errorf("read normals y %d: %w", i, err)
-		}
-		pos.Y = float32(val / 128)
-
-		err = binary.Read(r, binary.LittleEndian, &val)
-		if err != nil {
-			return fmt.Errorf("read normals z %d: %w", i, err)
-		}
-		pos.Z = float32(val / 128)
-
-		v.Normals = append(v.Normals, pos)
+		v.Normals = append(v.Normals, math32.Vector3{
+			X: float32(val[0]) / 128,
+			Y: float32(val[1]) / 128,
+			Z: float32(val[2]) / 128,
+		})
 	}
 
 	for i := 0; i < int(colorsCount); i++ {
@@ -321,95 +303,295 @@ func parseMesh(r io.ReadSeeker, v *Mesh, isNewWorldFormat bool) error {
 	}
 
 	for i := 0; i < int(polygonCount); i++ {
-		var notSolidFlag int16
-		err = binary.Read(r, binary.LittleEndian, &notSolidFlag)
+		p := &Polygon{}
+		err = binary.Read(r, binary.LittleEndian, &p.flags)
 		if err != nil {
 			return fmt.Errorf("read notSolidFlag %d: %w", i, err)
 		}
-		p := &Polygon{}
-		if notSolidFlag == 0 {
+		if p.flags == 0 {
 			//TODO: export separate collision flag
 			p.IsSolid = true
 		}
-		err = binary.Read(r, binary.LittleEndian, &p.Vertex1)
+		var index [3]uint16
+		err = binary.Read(r, binary.LittleEndian, &index)
 		if err != nil {
-			return fmt.Errorf("read vertex1 %d: %w", i, err)
+			return fmt.Errorf("read vertex index %d: %w", i, err)
 		}
+		p.Vertex1 = int(index[0])
+		p.Vertex2 = int(index[1])
+		p.Vertex3 = int(index[2])
 
-		err = binary.Read(r, binary.LittleEndian, &p.Vertex2)
+		v.Indices = append(v.Indices, p)
+	}
+
+	start := 0
+	for i := 0; i < int(vertexPieceCount); i++ {
+		var val [2]int16
+		err = binary.Read(r, binary.LittleEndian, &val)
 		if err != nil {
-			return fmt.Errorf("read vertex2 %d: %w", i, err)
+			return fmt.Errorf("read vertex piece %d: %w", i, err)
 		}
+		v.VertexPieces = append(v.VertexPieces, &MeshVertexPiece{Start: start, Count: int(val[0]), Bone: int(val[1])})
+		start += int(val[0])
+	}
 
-		err = binary.Read(r, binary.LittleEndian, &p.Vertex3)
+	for i := 0; i < int(polygonTextureCount); i++ {
+		var val [2]uint16
+		err = binary.Read(r, binary.LittleEndian, &val)
 		if err != nil {
-			return fmt.Errorf("read vertex3 %d: %w", i, err)
+			return fmt.Errorf("read polygon texture %d: %w", i, err)
 		}
+		v.RenderGroups = append(v.RenderGroups, &MeshRenderGroup{PolygonCount: int(val[0]), MaterialIndex: int(val[1])})
+	}
 
-		v.Indices = append(v.Indices, p)
+	for i := 0; i < int(vertexTextureCount); i++ {
+		var val [2]uint16
+		err = binary.Read(r, binary.LittleEndian, &val)
+		if err != nil {
+			return fmt.Errorf("read vertex texture %d: %w", i, err)
+		}
+		v.VertexTextures = append(v.VertexTextures, &MeshVertexTexture{VertexCount: int(val[0]), MaterialIndex: int(val[1])})
+	}
 
+	// size9 entries are unknown, 12 bytes each
+	if size9 > 0 {
+		v.size9 = make([]byte, int(size9)*12)
+		_, err = io.ReadFull(r, v.size9)
+		if err != nil {
+			return fmt.Errorf("read size9: %w", err)
+		}
 	}
 
-	/*
-	   MobPieces = new Dictionary<int, MobVertexPiece>();
-	   int mobStart = 0;
-
-	   for (int i = 0; i < vertexPieceCount; ++i)
-	   {
-	       int count = Reader.ReadInt16();
-	       int index1 = Reader.ReadInt16();
-	       var mobVertexPiece = new MobVertexPiece
-	       {
-	           Count = count,
-	           Start = mobStart
-	       };
-
-	       mobStart += count;
-
-	       MobPieces[index1] = mobVertexPiece;
-	   }
-
-	   MaterialGroups = new List<RenderGroup>();
-
-	   StartTextureIndex = Int32.MaxValue;
-
-	   for (int i = 0; i < polygonTextureCount; ++i)
-	   {
-	       var group = new RenderGroup();
-	       group.PolygonCount = Reader.ReadUInt16();
-	       group.MaterialIndex = Reader.ReadUInt16();
-	       MaterialGroups.Add(group);
-
-	       if (group.MaterialIndex < StartTextureIndex)
-	       {
-	           StartTextureIndex = group.MaterialIndex;
-	       }
-	   }
-
-	   for (int i = 0; i < vertexTextureCount; ++i)
-	   {
-	       Reader.BaseStream.Position += 4;
-	   }
-
-	   for (int i = 0; i < size9; ++i)
-	   {
-	       Reader.BaseStream.Position += 12;
-	   }
-
-	   // In some rare cases, the number of uvs does not match the number of vertices
-	   if (Vertices.Count != TextureUvCoordinates.Count)
-	   {
-	       int difference = Vertices.Count - TextureUvCoordinates.Count;
-
-	       for (int i = 0; i < difference; ++i)
-	       {
-	           TextureUvCoordinates.Add(new vec2(0.0f, 0.0f));
-	       }
-	   }
-	*/
+	// in some rare cases there are fewer uvs than vertices
+	for len(v.TextureUVCoordinates) < len(v.Verticies) {
+		v.TextureUVCoordinates = append(v.TextureUVCoordinates, math32.Vector2{})
+		v.paddedUVs++
+	}
 	return nil
 }
 
+// Write writes the mesh with vertices stored at Scale, see Quantize to pick one that fits
+func (v *Mesh) Write(w io.Writer) error {
+	flags := v.Flags
+	if flags == 0 {
+		flags = 0x00018003
+	}
+	err := binary.Write(w, binary.LittleEndian, []uint32{flags, v.MaterialReference, v.AnimationReference, v.unknowns[0], v.unknowns[1]})
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.Center)
+	if err != nil {
+		return fmt.Errorf("write center: %w", err)
+	}
+	err = binary.Write(w, binary.LittleEndian, v.centerUnknowns)
+	if err != nil {
+		return fmt.Errorf("write center unknowns: %w", err)
+	}
+	err = binary.Write(w, binary.LittleEndian, []float32{
+		v.MaxDistance,
+		v.MinPosition.X, v.MinPosition.Y, v.MinPosition.Z,
+		v.MaxPosition.X, v.MaxPosition.Y, v.MaxPosition.Z,
+	})
+	if err != nil {
+		return fmt.Errorf("write bounds: %w", err)
+	}
+
+	uvs := v.TextureUVCoordinates
+	if v.paddedUVs > 0 && v.paddedUVs <= len(uvs) && len(uvs) == len(v.Verticies) {
+		uvs = uvs[:len(uvs)-v.paddedUVs]
+	}
+	counts := []int{len(v.Verticies), len(uvs), len(v.Normals), len(v.Colors), len(v.Indices), len(v.VertexPieces), len(v.RenderGroups), len(v.VertexTextures), len(v.size9) / 12}
+	header := []uint16{}
+	for i, count := range counts {
+		if count > math.MaxUint16 {
+			return fmt.Errorf("count %d is %d, more than fits in 16 bits", i, count)
+		}
+		header = append(header, uint16(count))
+	}
+	if v.Scale < 0 || v.Scale > 15 {
+		return fmt.Errorf("scale %d is out of range", v.Scale)
+	}
+	header = append(header, uint16(v.Scale))
+	err = binary.Write(w, binary.LittleEndian, header)
+	if err != nil {
+		return fmt.Errorf("write counts: %w", err)
+	}
+
+	scale := float32(int(1) << uint(v.Scale))
+	for i, p := range v.Verticies {
+		var pos [3]int16
+		for j, c := range []float32{p.X, p.Y, p.Z} {
+			var ok bool
+			pos[j], ok = roundInt16(c * scale)
+			if !ok {
+				return fmt.Errorf("vertex %d %v does not fit in 16 bits at scale %d", i, p, v.Scale)
+			}
+		}
+		err = binary.Write(w, binary.LittleEndian, pos)
+		if err != nil {
+			return fmt.Errorf("write vertex %d: %w", i, err)
+		}
+	}
+
+	for i, uv := range uvs {
+		if v.IsNewWorldFormat {
+			pos := [2]int32{int32(round(uv.X * 256)), int32(round(uv.Y * 256))}
+			err = binary.Write(w, binary.LittleEndian, pos)
+			if err != nil {
+				return fmt.Errorf("write texture coordinate 32 %d: %w", i, err)
+			}
+			continue
+		}
+		var pos [2]int16
+		var okX, okY bool
+		pos[0], okX = roundInt16(uv.X * 256)
+		pos[1], okY = roundInt16(uv.Y * 256)
+		if !okX || !okY {
+			return fmt.Errorf("texture coordinate %d %v does not fit in 16 bits", i, uv)
+		}
+		err = binary.Write(w, binary.LittleEndian, pos)
+		if err != nil {
+			return fmt.Errorf("write texture coordinate 16 %d: %w", i, err)
+		}
+	}
+
+	for i, n := range v.Normals {
+		val := [3]int8{
+			int8(math32.Clamp(round(n.X*128), -128, 127)),
+			int8(math32.Clamp(round(n.Y*128), -128, 127)),
+			int8(math32.Clamp(round(n.Z*128), -128, 127)),
+		}
+		err = binary.Write(w, binary.LittleEndian, val)
+		if err != nil {
+			return fmt.Errorf("write normal %d: %w", i, err)
+		}
+	}
+
+	for i, c := range v.Colors {
+		err = binary.Write(w, binary.LittleEndian, []uint8{c.R, c.G, c.B, c.A})
+		if err != nil {
+			return fmt.Errorf("write color %d: %w", i, err)
+		}
+	}
+
+	for i, p := range v.Indices {
+		// decoded flags are kept, a polygon made not solid is flagged 0x10
+		notSolidFlag := p.flags
+		if p.IsSolid {
+			notSolidFlag = 0
+		} else if notSolidFlag == 0 {
+			notSolidFlag = 0x10
+		}
+		index := []uint16{notSolidFlag}
+		for _, vertex := range []int{p.Vertex1, p.Vertex2, p.Vertex3} {
+			if vertex < 0 || vertex > math.MaxUint16 {
+				return fmt.Errorf("polygon %d vertex %d is out of range", i, vertex)
+			}
+			index = append(index, uint16(vertex))
+		}
+		err = binary.Write(w, binary.LittleEndian, index)
+		if err != nil {
+			return fmt.Errorf("write polygon %d: %w", i, err)
+		}
+	}
+
+	for i, piece := range v.VertexPieces {
+		err = binary.Write(w, binary.LittleEndian, []int16{int16(piece.Count), int16(piece.Bone)})
+		if err != nil {
+			return fmt.Errorf("write vertex piece %d: %w", i, err)
+		}
+	}
+
+	for i, group := range v.RenderGroups {
+		err = binary.Write(w, binary.LittleEndian, []uint16{uint16(group.PolygonCount), uint16(group.MaterialIndex)})
+		if err != nil {
+			return fmt.Errorf("write polygon texture %d: %w", i, err)
+		}
+	}
+
+	for i, vt := range v.VertexTextures {
+		err = binary.Write(w, binary.LittleEndian, []uint16{uint16(vt.VertexCount), uint16(vt.MaterialIndex)})
+		if err != nil {
+			return fmt.Errorf("write vertex texture %d: %w", i, err)
+		}
+	}
+
+	_, err = w.Write(v.size9)
+	if err != nil {
+		return fmt.Errorf("write size9: %w", err)
+	}
+	return nil
+}
+
+// Quantize rounds vertices, uvs and normals to the precision they are stored with.
+// Scale is set to the finest shift that still fits every vertex in 16 bits
+func (v *Mesh) Quantize() error {
+	max := float32(0)
+	for _, p := range v.Verticies {
+		for _, c := range []float32{p.X, p.Y, p.Z} {
+			max = math32.Max(max, math32.Abs(c))
+		}
+	}
+	if max > math.MaxInt16 {
+		return fmt.Errorf("vertex %f from center does not fit in 16 bits", max)
+	}
+	v.Scale = 0
+	for v.Scale < 15 && max*float32(int(1)<<uint(v.Scale+1)) <= math.MaxInt16 {
+		v.Scale++
+	}
+
+	scale := float32(int(1) << uint(v.Scale))
+	for i := range v.Verticies {
+		p := &v.Verticies[i]
+		p.X = round(p.X*scale) / scale
+		p.Y = round(p.Y*scale) / scale
+		p.Z = round(p.Z*scale) / scale
+	}
+	for i := range v.TextureUVCoordinates {
+		uv := &v.TextureUVCoordinates[i]
+		uv.X = round(uv.X*256) / 256
+		uv.Y = round(uv.Y*256) / 256
+	}
+	for i := range v.Normals {
+		n := &v.Normals[i]
+		n.X = math32.Clamp(round(n.X*128), -128, 127) / 128
+		n.Y = math32.Clamp(round(n.Y*128), -128, 127) / 128
+		n.Z = math32.Clamp(round(n.Z*128), -128, 127) / 128
+	}
+	return nil
+}
+
+func round(f float32) float32 {
+	return float32(math.Round(float64(f)))
+}
+
+// roundInt16 rounds f to the nearest int16, false if it is out of range
+func roundInt16(f float32) (int16, bool) {
+	value := math.Round(float64(f))
+	if value < math.MinInt16 || value > math.MaxInt16 {
+		return 0, false
+	}
+	return int16(value), true
+}
+
 func (v *Mesh) FragmentType() string {
 	return "Mesh"
 }
+
+func (v *Mesh) FragmentCode() int32 {
+	return 0x36
+}
+
+func (v *Mesh) Name() string {
+	return v.name
+}
+
+func (v *Mesh) SetName(name string) {
+	v.name = name
+}
+
+func (v *Mesh) References() []uint32 {
+	return references(v.MaterialReference, v.AnimationReference)
+}
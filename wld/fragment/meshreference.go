@@ -11,8 +11,9 @@ import (
 // MeshReference information
 type MeshReference struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
-	Name      string
+	Flags     uint32
 	Position  math32.Vector3
 	Rotation  math32.Vector3
 	Scale     math32.Vector3
@@ -37,9 +38,12 @@ func parseMeshReference(r io.ReadSeeker, v *MeshReference) error {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	//TODO: name from hash
-
 	err = binary.Read(r, binary.LittleEndian, &v.Reference)
+	if err != nil {
+		return fmt.Errorf("read reference: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
@@ -50,3 +54,32 @@ func parseMeshReference(r io.ReadSeeker, v *MeshReference) error {
 func (v *MeshReference) FragmentType() string {
 	return "Mesh Reference"
 }
+
+func (v *MeshReference) FragmentCode() int32 {
+	return 0x2D
+}
+
+func (v *MeshReference) Name() string {
+	return v.name
+}
+
+func (v *MeshReference) SetName(name string) {
+	v.name = name
+}
+
+func (v *MeshReference) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *MeshReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.Reference)
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.Flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+	return nil
+}
@@ -11,10 +11,22 @@ import (
 // ObjectInstance information
 type ObjectInstance struct {
 	hashIndex uint32
-	Name      string
-	Position  math32.Vector3
-	Rotation  math32.Vector3
-	Scale     math32.Vector3
+	name      string
+	// ActorReference is the negative string hash offset of the actor placed, such as TREE_ACTORDEF
+	ActorReference int32
+	// ActorName is resolved from ActorReference when the wld is decoded
+	ActorName string
+	// Flags are 0x2E for the main zone and 0x32E for objects
+	Flags    uint32
+	Position math32.Vector3
+	Rotation math32.Vector3
+	Scale    math32.Vector3
+	// VertexColorReference points to a vertex color reference fragment lighting the object, 0 if none
+	VertexColorReference uint32
+	unknown2             uint32
+	// rotation and scale are as stored, written back while Rotation and Scale are unchanged
+	rotation [3]float32
+	scale    [3]float32
 }
 
 func LoadObjectInstance(r io.ReadSeeker) (*ObjectInstance, error) {
@@ -30,35 +42,35 @@ func parseObjectInstance(r io.ReadSeeker, v *ObjectInstance) error {
 	if v == nil {
 		return fmt.Errorf("object instance is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
-	//TODO: name from hash
+	err = binary.Read(r, binary.LittleEndian, &v.ActorReference)
+	if err != nil {
+		return fmt.Errorf("read actor reference: %w", err)
+	}
 
-	var flags uint32
-	err = binary.Read(r, binary.LittleEndian, &flags)
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
 	}
-	// Main zone: 0x2E, Objects: 0x32E
-	//TODO if flags != 0x2E && flags != 0x32E {
-	//	return fmt.Errorf("unknown flags want 0x2E or 0x32E, got 0x%x", flags)
+	//TODO if v.Flags != 0x2E && v.Flags != 0x32E {
+	//	return fmt.Errorf("unknown flags want 0x2E or 0x32E, got 0x%x", v.Flags)
 	//}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.unknown2)
 	if err != nil {
 		return fmt.Errorf("read unknown2: %w", err)
 	}
 
-	if flags == 0x2E && value != 0x16 {
-		return fmt.Errorf("expected unknown2 to be 0x16, got 0x%x", value)
+	if v.Flags == 0x2E && v.unknown2 != 0x16 {
+		return fmt.Errorf("expected unknown2 to be 0x16, got 0x%x", v.unknown2)
 	}
 
-	if flags == 0x32E && value != 0 {
-		return fmt.Errorf("expected unknown2 to be 0, got 0x%x", value)
+	if v.Flags == 0x32E && v.unknown2 != 0 {
+		return fmt.Errorf("expected unknown2 to be 0, got 0x%x", v.unknown2)
 	}
 
 	err = binary.Read(r, binary.LittleEndian, &v.Position.X)
@@ -74,55 +86,101 @@ func parseObjectInstance(r io.ReadSeeker, v *ObjectInstance) error {
 		return fmt.Errorf("read z: %w", err)
 	}
 
-	var rotX, rotY, rotZ float32
-	err = binary.Read(r, binary.LittleEndian, &rotX)
+	err = binary.Read(r, binary.LittleEndian, &v.rotation)
 	if err != nil {
-		return fmt.Errorf("read rotX: %w", err)
+		return fmt.Errorf("read rotation: %w", err)
 	}
+	v.Rotation = objectRotation(v.rotation)
 
-	err = binary.Read(r, binary.LittleEndian, &rotY)
+	err = binary.Read(r, binary.LittleEndian, &v.scale)
 	if err != nil {
-		return fmt.Errorf("read rotY: %w", err)
+		return fmt.Errorf("read scale: %w", err)
 	}
+	v.Scale = objectScale(v.scale)
 
-	err = binary.Read(r, binary.LittleEndian, &rotZ)
+	err = binary.Read(r, binary.LittleEndian, &v.VertexColorReference)
 	if err != nil {
-		return fmt.Errorf("read rotZ: %w", err)
+		return fmt.Errorf("read colorFragment: %w", err)
 	}
 
+	return nil
+}
+
+// objectRotation returns the rotation in degrees of a stored rotation, only the first two are used
+func objectRotation(rotation [3]float32) math32.Vector3 {
 	modifier := float32(float32(1) / float32(512) * 360)
-	v.Rotation.X = 0
-	v.Rotation.Y = rotY * modifier
-	v.Rotation.Z = -(rotX * modifier)
+	return math32.Vector3{
+		X: 0,
+		Y: rotation[1] * modifier,
+		Z: -(rotation[0] * modifier),
+	}
+}
+
+// objectScale returns the scale of a stored scale, only y is used
+func objectScale(scale [3]float32) math32.Vector3 {
+	return math32.Vector3{X: scale[1], Y: scale[1], Z: scale[1]}
+}
 
-	err = binary.Read(r, binary.LittleEndian, &rotX)
+func (v *ObjectInstance) FragmentType() string {
+	return "Object Instance"
+}
+
+func (v *ObjectInstance) FragmentCode() int32 {
+	return 0x15
+}
+
+func (v *ObjectInstance) Name() string {
+	return v.name
+}
+
+func (v *ObjectInstance) SetName(name string) {
+	v.name = name
+}
+
+func (v *ObjectInstance) References() []uint32 {
+	return references(v.VertexColorReference)
+}
+
+func (v *ObjectInstance) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.ActorReference)
 	if err != nil {
-		return fmt.Errorf("read scaleX: %w", err)
+		return fmt.Errorf("write actor reference: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &rotY)
+	unknown2 := v.unknown2
+	if v.Flags == 0x2E {
+		unknown2 = 0x16
+	}
+	err = binary.Write(w, binary.LittleEndian, []uint32{v.Flags, unknown2})
 	if err != nil {
-		return fmt.Errorf("read scaleY: %w", err)
+		return fmt.Errorf("write flags: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &rotZ)
+	rotation := v.rotation
+	if objectRotation(rotation) != v.Rotation {
+		modifier := float32(float32(1) / float32(512) * 360)
+		rotation = [3]float32{-v.Rotation.Z / modifier, v.Rotation.Y / modifier, 0}
+	}
+	scale := v.scale
+	if objectScale(scale) != v.Scale {
+		scale = [3]float32{v.Scale.X, v.Scale.Y, v.Scale.Z}
+	}
+	err = binary.Write(w, binary.LittleEndian, v.Position)
 	if err != nil {
-		return fmt.Errorf("read scaleZ: %w", err)
+		return fmt.Errorf("write position: %w", err)
 	}
-
-	v.Scale.X, v.Scale.Y, v.Scale.Z = rotY, rotY, rotY
-
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Write(w, binary.LittleEndian, rotation)
 	if err != nil {
-		return fmt.Errorf("read colorFragment: %w", err)
+		return fmt.Errorf("write rotation: %w", err)
+	}
+	err = binary.Write(w, binary.LittleEndian, scale)
+	if err != nil {
+		return fmt.Errorf("write scale: %w", err)
 	}
-	//if value != 0 {
-	//TODO: look up vertexcolorreference
-	//}
 
+	err = binary.Write(w, binary.LittleEndian, v.VertexColorReference)
+	if err != nil {
+		return fmt.Errorf("write colorFragment: %w", err)
+	}
 	return nil
 }
-
-func (v *ObjectInstance) FragmentType() string {
-	return "Object Instance"
-}
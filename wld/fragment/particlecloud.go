@@ -1,6 +1,7 @@
 package fragment
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -9,6 +10,9 @@ import (
 // ParticleCloud information
 type ParticleCloud struct {
 	hashIndex uint32
+	name      string
+	// data is the 80 bytes of values after the name, none of which are understood yet
+	data []byte
 }
 
 func LoadParticleCloud(r io.ReadSeeker) (*ParticleCloud, error) {
@@ -30,6 +34,13 @@ func parseParticleCloud(r io.ReadSeeker, v *ParticleCloud) error {
 		return fmt.Errorf("read hash index: %w", err)
 	}
 
+	v.data = make([]byte, 80)
+	_, err = io.ReadFull(r, v.data)
+	if err != nil {
+		return fmt.Errorf("read values: %w", err)
+	}
+	r = bytes.NewReader(v.data)
+
 	err = binary.Read(r, binary.LittleEndian, &value)
 	if err != nil {
 		return fmt.Errorf("read flags: %w", err)
@@ -173,3 +184,35 @@ func parseParticleCloud(r io.ReadSeeker, v *ParticleCloud) error {
 func (v *ParticleCloud) FragmentType() string {
 	return "Particle Cloud"
 }
+
+func (v *ParticleCloud) FragmentCode() int32 {
+	return 0x34
+}
+
+func (v *ParticleCloud) Name() string {
+	return v.name
+}
+
+func (v *ParticleCloud) SetName(name string) {
+	v.name = name
+}
+
+func (v *ParticleCloud) References() []uint32 {
+	return nil
+}
+
+func (v *ParticleCloud) Write(w io.Writer) error {
+	data := v.data
+	if data == nil {
+		// the fewest values a cloud is read back with
+		data = make([]byte, 80)
+		binary.LittleEndian.PutUint32(data, 4)
+		binary.LittleEndian.PutUint32(data[4:], 3)
+		binary.LittleEndian.PutUint32(data[8:], 1)
+	}
+	_, err := w.Write(data)
+	if err != nil {
+		return fmt.Errorf("write values: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 // ParticleSprite information
 type ParticleSprite struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
 }
 
@@ -52,3 +53,28 @@ func parseParticleSprite(r io.ReadSeeker, v *ParticleSprite) error {
 func (v *ParticleSprite) FragmentType() string {
 	return "Particle Sprite"
 }
+
+func (v *ParticleSprite) FragmentCode() int32 {
+	return 0x26
+}
+
+func (v *ParticleSprite) Name() string {
+	return v.name
+}
+
+func (v *ParticleSprite) SetName(name string) {
+	v.name = name
+}
+
+func (v *ParticleSprite) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *ParticleSprite) Write(w io.Writer) error {
+	// value4 and value12 are not kept, and written as 0
+	err := binary.Write(w, binary.LittleEndian, []uint32{0, v.Reference, 0})
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 // ParticleSpriteReference information
 type ParticleSpriteReference struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
 }
 
@@ -50,3 +51,27 @@ func parseParticleSpriteReference(r io.ReadSeeker, v *ParticleSpriteReference) e
 func (v *ParticleSpriteReference) FragmentType() string {
 	return "Particle Sprite Reference"
 }
+
+func (v *ParticleSpriteReference) FragmentCode() int32 {
+	return 0x27
+}
+
+func (v *ParticleSpriteReference) Name() string {
+	return v.name
+}
+
+func (v *ParticleSpriteReference) SetName(name string) {
+	v.name = name
+}
+
+func (v *ParticleSpriteReference) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *ParticleSpriteReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, []uint32{v.Reference, 8})
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+	return nil
+}
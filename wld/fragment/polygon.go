@@ -5,4 +5,6 @@ type Polygon struct {
 	Vertex1 int
 	Vertex2 int
 	Vertex3 int
+	// flags are as stored, any set means not solid
+	flags uint16
 }
@@ -0,0 +1,264 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+)
+
+// RegionType is what a region is made of, numbered the same as EQEmu water maps
+type RegionType int
+
+const (
+	RegionTypeNormal   RegionType = 0
+	RegionTypeWater    RegionType = 1
+	RegionTypeLava     RegionType = 2
+	RegionTypeZoneLine RegionType = 3
+	RegionTypePVP      RegionType = 4
+	RegionTypeSlime    RegionType = 5
+	// RegionTypeIce is slippery ground
+	RegionTypeIce RegionType = 6
+	// RegionTypeVWater is freezing water
+	RegionTypeVWater RegionType = 7
+)
+
+func (t RegionType) String() string {
+	switch t {
+	case RegionTypeNormal:
+		return "normal"
+	case RegionTypeWater:
+		return "water"
+	case RegionTypeLava:
+		return "lava"
+	case RegionTypeZoneLine:
+		return "zone line"
+	case RegionTypePVP:
+		return "pvp"
+	case RegionTypeSlime:
+		return "slime"
+	case RegionTypeIce:
+		return "ice"
+	case RegionTypeVWater:
+		return "vwater"
+	}
+	return fmt.Sprintf("unknown %d", int(t))
+}
+
+// RegionFlag information, tags a list of bsp regions with a type such as WT_ZONE or DRNTP zone lines
+type RegionFlag struct {
+	hashIndex uint32
+	name      string
+	Flags     uint32
+	// RegionIndices are the bsp regions tagged, counting from 0
+	RegionIndices []int
+	// UserData overrides the name as the tag when set
+	UserData string
+}
+
+// ZoneLine is where a zone line region sends a player
+type ZoneLine struct {
+	ZoneID int
+	// PointIndex is the server zone point used when ZoneID is 255, in place of Position and Heading
+	PointIndex int
+	Position   math32.Vector3
+	Heading    int
+}
+
+func LoadRegionFlag(r io.ReadSeeker) (*RegionFlag, error) {
+	v := &RegionFlag{}
+	err := parseRegionFlag(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse region flag: %w", err)
+	}
+	return v, nil
+}
+
+func parseRegionFlag(r io.ReadSeeker, v *RegionFlag) error {
+	if v == nil {
+		return fmt.Errorf("region flag is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	var regionCount uint32
+	err = binary.Read(r, binary.LittleEndian, &regionCount)
+	if err != nil {
+		return fmt.Errorf("read region count: %w", err)
+	}
+
+	for i := uint32(0); i < regionCount; i++ {
+		var value uint32
+		err = binary.Read(r, binary.LittleEndian, &value)
+		if err != nil {
+			return fmt.Errorf("read %d region index: %w", i, err)
+		}
+		v.RegionIndices = append(v.RegionIndices, int(value))
+	}
+
+	var userDataSize uint32
+	err = binary.Read(r, binary.LittleEndian, &userDataSize)
+	if err != nil {
+		return fmt.Errorf("read user data size: %w", err)
+	}
+	if userDataSize == 0 {
+		return nil
+	}
+	userData := make([]byte, userDataSize)
+	_, err = io.ReadFull(r, userData)
+	if err != nil {
+		return fmt.Errorf("read user data: %w", err)
+	}
+	v.UserData = strings.TrimRight(string(DecodeString(userData)), "\x00")
+	return nil
+}
+
+// Tag returns the string the region types are read from
+func (v *RegionFlag) Tag() string {
+	if v.UserData != "" {
+		return v.UserData
+	}
+	return v.name
+}
+
+// Types returns the region types of the tag, such as water for WT_ZONE
+func (v *RegionFlag) Types() []RegionType {
+	tag := strings.ToLower(v.Tag())
+	switch {
+	case strings.HasPrefix(tag, "wtntp"):
+		return []RegionType{RegionTypeWater, RegionTypeZoneLine}
+	case strings.HasPrefix(tag, "wtn_"), strings.HasPrefix(tag, "wt_"):
+		return []RegionType{RegionTypeWater}
+	case strings.HasPrefix(tag, "lantp"):
+		return []RegionType{RegionTypeLava, RegionTypeZoneLine}
+	case strings.HasPrefix(tag, "lan_"), strings.HasPrefix(tag, "la_"):
+		return []RegionType{RegionTypeLava}
+	case strings.HasPrefix(tag, "drntp"):
+		return []RegionType{RegionTypeZoneLine}
+	case strings.HasPrefix(tag, "drp_"):
+		return []RegionType{RegionTypePVP}
+	case strings.HasPrefix(tag, "sln_"), strings.HasPrefix(tag, "sl_"):
+		return []RegionType{RegionTypeSlime}
+	case strings.HasPrefix(tag, "vwn_"):
+		return []RegionType{RegionTypeVWater}
+	case strings.HasPrefix(tag, "drn_") && strings.Contains(tag, "_s_"):
+		return []RegionType{RegionTypeIce}
+	}
+	return []RegionType{RegionTypeNormal}
+}
+
+// ZoneLine decodes the destination of a zone line tag, nil if the tag is not a zone line.
+// Tags are DRNTP (or WTNTP, LANTP), a 5 digit zone id, then either a 6 digit zone point index when the
+// zone id is 255, or 6 digit x, y and z and a 3 digit heading
+func (v *RegionFlag) ZoneLine() (*ZoneLine, error) {
+	tag := strings.ToLower(v.Tag())
+	if !strings.HasPrefix(tag, "drntp") && !strings.HasPrefix(tag, "wtntp") && !strings.HasPrefix(tag, "lantp") {
+		return nil, nil
+	}
+	if strings.HasPrefix(tag[5:], "_zone") {
+		return &ZoneLine{ZoneID: 255}, nil
+	}
+	field := func(offset int, size int) (int, error) {
+		if len(tag) < offset+size {
+			return 0, fmt.Errorf("%s too short", v.Tag())
+		}
+		value, err := strconv.Atoi(tag[offset : offset+size])
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", v.Tag(), err)
+		}
+		return value, nil
+	}
+	zl := &ZoneLine{}
+	var err error
+	zl.ZoneID, err = field(5, 5)
+	if err != nil {
+		return nil, fmt.Errorf("zone id: %w", err)
+	}
+	if zl.ZoneID == 255 {
+		zl.PointIndex, err = field(10, 6)
+		if err != nil {
+			return nil, fmt.Errorf("point index: %w", err)
+		}
+		return zl, nil
+	}
+	x, err := field(10, 6)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := field(16, 6)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	z, err := field(22, 6)
+	if err != nil {
+		return nil, fmt.Errorf("z: %w", err)
+	}
+	zl.Position = math32.Vector3{X: float32(x), Y: float32(y), Z: float32(z)}
+	zl.Heading, err = field(28, 3)
+	if err != nil {
+		return nil, fmt.Errorf("heading: %w", err)
+	}
+	return zl, nil
+}
+
+func (v *RegionFlag) FragmentType() string {
+	return "Region Flag"
+}
+
+func (v *RegionFlag) FragmentCode() int32 {
+	return 0x29
+}
+
+func (v *RegionFlag) Name() string {
+	return v.name
+}
+
+func (v *RegionFlag) SetName(name string) {
+	v.name = name
+}
+
+func (v *RegionFlag) References() []uint32 {
+	return nil
+}
+
+func (v *RegionFlag) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, []uint32{v.Flags, uint32(len(v.RegionIndices))})
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	for i, index := range v.RegionIndices {
+		err = binary.Write(w, binary.LittleEndian, uint32(index))
+		if err != nil {
+			return fmt.Errorf("write %d region index: %w", i, err)
+		}
+	}
+
+	if v.UserData == "" {
+		err = binary.Write(w, binary.LittleEndian, uint32(0))
+		if err != nil {
+			return fmt.Errorf("write user data size: %w", err)
+		}
+		return nil
+	}
+	userData := DecodeString([]byte(v.UserData + "\x00"))
+	err = binary.Write(w, binary.LittleEndian, uint32(len(userData)))
+	if err != nil {
+		return fmt.Errorf("write user data size: %w", err)
+	}
+	_, err = w.Write(userData)
+	if err != nil {
+		return fmt.Errorf("write user data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// SkeletonHierarchy information, the bone tree of an animated model
+type SkeletonHierarchy struct {
+	hashIndex uint32
+	name      string
+	Flags     uint32
+	// PolygonAnimationReference points to a 0x18 polygon animation fragment, usually 0
+	PolygonAnimationReference uint32
+	BoundingRadius            float32
+	// Bones are stored parent first, the first bone is the root
+	Bones []*SkeletonBone
+	// MeshReferences point to the mesh reference fragments skinned to this skeleton
+	MeshReferences []uint32
+	// params are the 3 unknown params of flag bit 0
+	params [3]uint32
+	// meshUnknowns are the unknown values stored after the mesh references, one each
+	meshUnknowns []uint32
+}
+
+// SkeletonBone is a node of a skeleton hierarchy
+type SkeletonBone struct {
+	// NameReference is the negative string hash offset of the bone name
+	NameReference int32
+	// Name is resolved from NameReference when the wld is decoded
+	Name  string
+	Flags uint32
+	// TrackReference points to the track reference fragment that animates the bone
+	TrackReference uint32
+	// MeshReference points to a mesh reference attached to the bone, 0 if none
+	MeshReference uint32
+	// Parent is the index of the parent bone, -1 for the root
+	Parent   int
+	Children []int
+}
+
+func LoadSkeletonHierarchy(r io.ReadSeeker) (*SkeletonHierarchy, error) {
+	v := &SkeletonHierarchy{}
+	err := parseSkeletonHierarchy(r, v)
+	if err != nil {
+		return nil, fmt.Errorf("parse skeleton hierarchy: %w", err)
+	}
+	return v, nil
+}
+
+func parseSkeletonHierarchy(r io.ReadSeeker, v *SkeletonHierarchy) error {
+	if v == nil {
+		return fmt.Errorf("skeleton hierarchy is nil")
+	}
+	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
+	if err != nil {
+		return fmt.Errorf("read hash index: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	var boneCount uint32
+	err = binary.Read(r, binary.LittleEndian, &boneCount)
+	if err != nil {
+		return fmt.Errorf("read bone count: %w", err)
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &v.PolygonAnimationReference)
+	if err != nil {
+		return fmt.Errorf("read polygon animation reference: %w", err)
+	}
+
+	// bit 0 has 3 unknown params
+	if v.Flags&0x1 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.params)
+		if err != nil {
+			return fmt.Errorf("read params: %w", err)
+		}
+	}
+
+	if v.Flags&0x2 != 0 {
+		err = binary.Read(r, binary.LittleEndian, &v.BoundingRadius)
+		if err != nil {
+			return fmt.Errorf("read bounding radius: %w", err)
+		}
+	}
+
+	for i := uint32(0); i < boneCount; i++ {
+		bone := &SkeletonBone{Parent: -1}
+		err = binary.Read(r, binary.LittleEndian, &bone.NameReference)
+		if err != nil {
+			return fmt.Errorf("read bone %d name reference: %w", i, err)
+		}
+		err = binary.Read(r, binary.LittleEndian, &bone.Flags)
+		if err != nil {
+			return fmt.Errorf("read bone %d flags: %w", i, err)
+		}
+		err = binary.Read(r, binary.LittleEndian, &bone.TrackReference)
+		if err != nil {
+			return fmt.Errorf("read bone %d track reference: %w", i, err)
+		}
+		err = binary.Read(r, binary.LittleEndian, &bone.MeshReference)
+		if err != nil {
+			return fmt.Errorf("read bone %d mesh reference: %w", i, err)
+		}
+		var childCount uint32
+		err = binary.Read(r, binary.LittleEndian, &childCount)
+		if err != nil {
+			return fmt.Errorf("read bone %d child count: %w", i, err)
+		}
+		for j := uint32(0); j < childCount; j++ {
+			var child uint32
+			err = binary.Read(r, binary.LittleEndian, &child)
+			if err != nil {
+				return fmt.Errorf("read bone %d child %d: %w", i, j, err)
+			}
+			bone.Children = append(bone.Children, int(child))
+		}
+		v.Bones = append(v.Bones, bone)
+	}
+
+	for i, bone := range v.Bones {
+		for _, child := range bone.Children {
+			if child < 0 || child >= len(v.Bones) {
+				return fmt.Errorf("bone %d child %d out of range", i, child)
+			}
+			v.Bones[child].Parent = i
+		}
+	}
+
+	// bit 9 has mesh references, each followed later by an unknown value
+	if v.Flags&0x200 != 0 {
+		var meshCount uint32
+		err = binary.Read(r, binary.LittleEndian, &meshCount)
+		if err != nil {
+			return fmt.Errorf("read mesh reference count: %w", err)
+		}
+		for i := uint32(0); i < meshCount; i++ {
+			var value uint32
+			err = binary.Read(r, binary.LittleEndian, &value)
+			if err != nil {
+				return fmt.Errorf("read %d mesh reference: %w", i, err)
+			}
+			v.MeshReferences = append(v.MeshReferences, value)
+		}
+		for i := uint32(0); i < meshCount; i++ {
+			var value uint32
+			err = binary.Read(r, binary.LittleEndian, &value)
+			if err != nil {
+				return fmt.Errorf("read %d mesh reference unknown: %w", i, err)
+			}
+			v.meshUnknowns = append(v.meshUnknowns, value)
+		}
+	}
+	return nil
+}
+
+func (v *SkeletonHierarchy) FragmentType() string {
+	return "Skeleton Hierarchy"
+}
+
+func (v *SkeletonHierarchy) FragmentCode() int32 {
+	return 0x10
+}
+
+func (v *SkeletonHierarchy) Name() string {
+	return v.name
+}
+
+func (v *SkeletonHierarchy) SetName(name string) {
+	v.name = name
+}
+
+func (v *SkeletonHierarchy) References() []uint32 {
+	refs := references(v.PolygonAnimationReference)
+	for _, bone := range v.Bones {
+		refs = append(refs, references(bone.TrackReference, bone.MeshReference)...)
+	}
+	return append(refs, v.MeshReferences...)
+}
+
+func (v *SkeletonHierarchy) Write(w io.Writer) error {
+	flags := v.Flags &^ 0x200
+	if len(v.MeshReferences) > 0 {
+		flags |= 0x200
+	}
+	err := binary.Write(w, binary.LittleEndian, flags)
+	if err != nil {
+		return fmt.Errorf("write flags: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, uint32(len(v.Bones)))
+	if err != nil {
+		return fmt.Errorf("write bone count: %w", err)
+	}
+
+	err = binary.Write(w, binary.LittleEndian, v.PolygonAnimationReference)
+	if err != nil {
+		return fmt.Errorf("write polygon animation reference: %w", err)
+	}
+
+	if flags&0x1 != 0 {
+		err = binary.Write(w, binary.LittleEndian, v.params)
+		if err != nil {
+			return fmt.Errorf("write params: %w", err)
+		}
+	}
+
+	if flags&0x2 != 0 {
+		err = binary.Write(w, binary.LittleEndian, v.BoundingRadius)
+		if err != nil {
+			return fmt.Errorf("write bounding radius: %w", err)
+		}
+	}
+
+	for i, bone := range v.Bones {
+		err = binary.Write(w, binary.LittleEndian, bone.NameReference)
+		if err != nil {
+			return fmt.Errorf("write bone %d name reference: %w", i, err)
+		}
+		err = binary.Write(w, binary.LittleEndian, []uint32{bone.Flags, bone.TrackReference, bone.MeshReference, uint32(len(bone.Children))})
+		if err != nil {
+			return fmt.Errorf("write bone %d: %w", i, err)
+		}
+		for j, child := range bone.Children {
+			err = binary.Write(w, binary.LittleEndian, uint32(child))
+			if err != nil {
+				return fmt.Errorf("write bone %d child %d: %w", i, j, err)
+			}
+		}
+	}
+
+	if flags&0x200 != 0 {
+		err = binary.Write(w, binary.LittleEndian, uint32(len(v.MeshReferences)))
+		if err != nil {
+			return fmt.Errorf("write mesh reference count: %w", err)
+		}
+		err = binary.Write(w, binary.LittleEndian, v.MeshReferences)
+		if err != nil {
+			return fmt.Errorf("write mesh references: %w", err)
+		}
+		// mesh references added since decoding have an unknown of 0
+		unknowns := make([]uint32, len(v.MeshReferences))
+		copy(unknowns, v.meshUnknowns)
+		err = binary.Write(w, binary.LittleEndian, unknowns)
+		if err != nil {
+			return fmt.Errorf("write mesh reference unknowns: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package fragment
+
+// stringKey is xor'd over the string hash of every wld and the file names of bitmap names
+var stringKey = []byte{0x95, 0x3A, 0xC5, 0x2A, 0x95, 0x7A, 0x95, 0x6A}
+
+// DecodeString reverses the xor encoding wld uses for strings. Encoding is the same operation
+func DecodeString(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, char := range data {
+		out[i] = char ^ stringKey[i%len(stringKey)]
+	}
+	return out
+}
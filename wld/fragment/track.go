@@ -11,6 +11,7 @@ import (
 // Track information
 type Track struct {
 	hashIndex uint32
+	name      string
 	Frames    []*BoneTransform
 }
 
@@ -20,6 +21,8 @@ type BoneTransform struct {
 	Rotation    math32.Quaternion
 	Scale       float32
 	ModelMatrix math32.Matrix4
+	// frame is as stored, written back while Translation, Rotation and Scale are unchanged
+	frame [8]int16
 }
 
 func LoadTrack(r io.ReadSeeker) (*Track, error) {
@@ -54,75 +57,100 @@ func parseTrack(r io.ReadSeeker, v *Track) error {
 		return fmt.Errorf("unknown track type: expected 8, got %d", value)
 	}
 
-	var frameCount int
+	var frameCount uint32
 	err = binary.Read(r, binary.LittleEndian, &frameCount)
 	if err != nil {
-		return fmt.Errorf("read flag: %w", err)
+		return fmt.Errorf("read frame count: %w", err)
 	}
-	for i := 0; i < frameCount; i++ {
-		var rotDenominator, shiftDenominator int
-		var rotX, rotY, rotZ int
-		var shiftX, shiftY, shiftZ int
-
-		err = binary.Read(r, binary.LittleEndian, &rotDenominator)
+	for i := uint32(0); i < frameCount; i++ {
+		ft := &BoneTransform{}
+		// rotation denominator, rotation x, y and z, shift x, y and z, then the shift denominator
+		err = binary.Read(r, binary.LittleEndian, &ft.frame)
 		if err != nil {
-			return fmt.Errorf("read rot denominator: %w", err)
+			return fmt.Errorf("read frame %d: %w", i, err)
 		}
+		ft.Translation, ft.Rotation, ft.Scale = boneTransform(ft.frame)
+		v.Frames = append(v.Frames, ft)
+	}
 
-		err = binary.Read(r, binary.LittleEndian, &rotX)
-		if err != nil {
-			return fmt.Errorf("read rot x: %w", err)
-		}
+	return nil
+}
 
-		err = binary.Read(r, binary.LittleEndian, &rotY)
-		if err != nil {
-			return fmt.Errorf("read rot y: %w", err)
-		}
+// boneTransform returns the translation, rotation and scale of a stored frame.
+// A frame without a rotation is left unrotated
+func boneTransform(frame [8]int16) (math32.Vector3, math32.Quaternion, float32) {
+	var translation math32.Vector3
+	var scale float32
+	if frame[7] != 0 {
+		scale = float32(frame[7]) / 256
+		translation.X = float32(frame[4]) / 256
+		translation.Y = float32(frame[5]) / 256
+		translation.Z = float32(frame[6]) / 256
+	}
+	rotation := math32.Quaternion{W: 1}
+	if frame[0] != 0 || frame[1] != 0 || frame[2] != 0 || frame[3] != 0 {
+		rotation = math32.Quaternion{X: float32(frame[1]), Y: float32(frame[2]), Z: float32(frame[3]), W: float32(frame[0])}
+		rotation.Normalize()
+	}
+	return translation, rotation, scale
+}
 
-		err = binary.Read(r, binary.LittleEndian, &rotZ)
-		if err != nil {
-			return fmt.Errorf("read rot z: %w", err)
-		}
+func (v *Track) FragmentType() string {
+	return "Track"
+}
 
-		err = binary.Read(r, binary.LittleEndian, &shiftX)
-		if err != nil {
-			return fmt.Errorf("read shift x: %w", err)
-		}
+func (v *Track) FragmentCode() int32 {
+	return 0x12
+}
 
-		err = binary.Read(r, binary.LittleEndian, &shiftY)
-		if err != nil {
-			return fmt.Errorf("read shift y: %w", err)
-		}
+func (v *Track) Name() string {
+	return v.name
+}
 
-		err = binary.Read(r, binary.LittleEndian, &shiftZ)
-		if err != nil {
-			return fmt.Errorf("read shift z: %w", err)
-		}
+func (v *Track) SetName(name string) {
+	v.name = name
+}
 
-		err = binary.Read(r, binary.LittleEndian, &shiftDenominator)
-		if err != nil {
-			return fmt.Errorf("read shift denominator: %w", err)
-		}
+func (v *Track) References() []uint32 {
+	return nil
+}
 
-		ft := &BoneTransform{}
+func (v *Track) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, uint32(8))
+	if err != nil {
+		return fmt.Errorf("write flag: %w", err)
+	}
 
-		if shiftDenominator != 0 {
-			ft.Scale = float32(shiftDenominator) / 256
-			ft.Translation.X = float32(shiftX / 256)
-			ft.Translation.Y = float32(shiftY / 256)
-			ft.Translation.Z = float32(shiftZ / 256)
+	err = binary.Write(w, binary.LittleEndian, uint32(len(v.Frames)))
+	if err != nil {
+		return fmt.Errorf("write frame count: %w", err)
+	}
+	for i, ft := range v.Frames {
+		translation, rotation, scale := boneTransform(ft.frame)
+		if translation == ft.Translation && rotation == ft.Rotation && scale == ft.Scale {
+			err = binary.Write(w, binary.LittleEndian, ft.frame)
+			if err != nil {
+				return fmt.Errorf("write frame %d: %w", i, err)
+			}
+			continue
+		}
+		// rotations are stored over a denominator of 1<<14, translations and scale over 256
+		values := []float32{
+			ft.Rotation.W * 16384, ft.Rotation.X * 16384, ft.Rotation.Y * 16384, ft.Rotation.Z * 16384,
+			ft.Translation.X * 256, ft.Translation.Y * 256, ft.Translation.Z * 256, ft.Scale * 256,
+		}
+		frame := make([]int16, len(values))
+		for j, value := range values {
+			var ok bool
+			frame[j], ok = roundInt16(value)
+			if !ok {
+				return fmt.Errorf("frame %d value %f does not fit in 16 bits", i, value)
+			}
+		}
+		err = binary.Write(w, binary.LittleEndian, frame)
+		if err != nil {
+			return fmt.Errorf("write frame %d: %w", i, err)
 		}
-		ft.Rotation.X = float32(rotX)
-		ft.Rotation.Y = float32(rotY)
-		ft.Rotation.Z = float32(rotZ)
-		ft.Rotation.W = float32(rotDenominator)
-		ft.Rotation.Normalize()
-		v.Frames = append(v.Frames, ft)
 	}
-
 	return nil
 }
-
-func (v *Track) FragmentType() string {
-	return "Track "
-}
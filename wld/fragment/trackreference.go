@@ -9,7 +9,9 @@ import (
 // TrackReference information
 type TrackReference struct {
 	hashIndex uint32
+	name      string
 	Reference uint32
+	Flags     uint32
 	FrameMs   uint32
 }
 
@@ -26,7 +28,6 @@ func parseTrackReference(r io.ReadSeeker, v *TrackReference) error {
 	if v == nil {
 		return fmt.Errorf("track reference is nil")
 	}
-	var value uint32
 	err := binary.Read(r, binary.LittleEndian, &v.hashIndex)
 	if err != nil {
 		return fmt.Errorf("read hash index: %w", err)
@@ -37,7 +38,7 @@ func parseTrackReference(r io.ReadSeeker, v *TrackReference) error {
 		return fmt.Errorf("read reference: %w", err)
 	}
 
-	err = binary.Read(r, binary.LittleEndian, &value)
+	err = binary.Read(r, binary.LittleEndian, &v.Flags)
 	if err != nil {
 		return fmt.Errorf("read flag: %w", err)
 	}
@@ -46,7 +47,7 @@ func parseTrackReference(r io.ReadSeeker, v *TrackReference) error {
 	// Either 4 or 5 - maybe something to look into
 	// Bits are set 0, or 2. 0 has the extra field for delay.
 	// 2 doesn't have any additional fields.
-	if value&1 == 1 {
+	if v.Flags&1 == 1 {
 		err = binary.Read(r, binary.LittleEndian, &v.FrameMs)
 		if err != nil {
 			return fmt.Errorf("read frame ms: %w", err)
@@ -59,3 +60,43 @@ func parseTrackReference(r io.ReadSeeker, v *TrackReference) error {
 func (v *TrackReference) FragmentType() string {
 	return "Track Reference"
 }
+
+func (v *TrackReference) FragmentCode() int32 {
+	return 0x13
+}
+
+func (v *TrackReference) Name() string {
+	return v.name
+}
+
+func (v *TrackReference) SetName(name string) {
+	v.name = name
+}
+
+func (v *TrackReference) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *TrackReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.Reference)
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+
+	flags := v.Flags
+	if v.FrameMs != 0 {
+		flags |= 1
+	}
+	err = binary.Write(w, binary.LittleEndian, flags)
+	if err != nil {
+		return fmt.Errorf("write flag: %w", err)
+	}
+
+	if flags&1 == 1 {
+		err = binary.Write(w, binary.LittleEndian, v.FrameMs)
+		if err != nil {
+			return fmt.Errorf("write frame ms: %w", err)
+		}
+	}
+	return nil
+}
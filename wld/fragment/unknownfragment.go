@@ -0,0 +1,76 @@
+package fragment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// UnknownFragment is a fragment of a type that is not understood yet, kept raw so it writes back unchanged
+type UnknownFragment struct {
+	// Code is the type code the fragment is stored with
+	Code int32
+	// NameReference is the negative string hash offset of the name
+	NameReference int32
+	name          string
+	// Data is the body of the fragment after its name reference
+	Data []byte
+}
+
+func LoadUnknownFragment(r io.ReadSeeker, code int32, size uint32) (*UnknownFragment, error) {
+	v := &UnknownFragment{Code: code}
+	err := parseUnknownFragment(r, v, size)
+	if err != nil {
+		return nil, fmt.Errorf("parse unknown fragment: %w", err)
+	}
+	return v, nil
+}
+
+func parseUnknownFragment(r io.ReadSeeker, v *UnknownFragment, size uint32) error {
+	if v == nil {
+		return fmt.Errorf("unknown fragment is nil")
+	}
+	if size >= 4 {
+		err := binary.Read(r, binary.LittleEndian, &v.NameReference)
+		if err != nil {
+			return fmt.Errorf("read name reference: %w", err)
+		}
+		size -= 4
+	}
+
+	v.Data = make([]byte, size)
+	_, err := io.ReadFull(r, v.Data)
+	if err != nil {
+		return fmt.Errorf("read data: %w", err)
+	}
+	return nil
+}
+
+func (v *UnknownFragment) FragmentType() string {
+	return fmt.Sprintf("Unknown 0x%x", v.Code)
+}
+
+func (v *UnknownFragment) FragmentCode() int32 {
+	return v.Code
+}
+
+func (v *UnknownFragment) Name() string {
+	return v.name
+}
+
+func (v *UnknownFragment) SetName(name string) {
+	v.name = name
+}
+
+// References is always empty, as what the data points to is not known
+func (v *UnknownFragment) References() []uint32 {
+	return nil
+}
+
+func (v *UnknownFragment) Write(w io.Writer) error {
+	_, err := w.Write(v.Data)
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
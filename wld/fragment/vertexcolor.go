@@ -12,6 +12,7 @@ type VertexColor struct {
 	// Colors of the vertex, if applicable
 	Colors    []color.RGBA
 	hashIndex uint32
+	name      string
 }
 
 func LoadVertexColor(r io.ReadSeeker) (*VertexColor, error) {
@@ -70,25 +71,14 @@ func parseVertexColor(r io.ReadSeeker, v *VertexColor) error {
 	//if value != 0 {
 	//	return fmt.Errorf("unknown 4 expected %d, got %d", 0, value)
 	//}
-	for i := 0; i < int(vertexColorCount)/4; i++ {
-		rgba := color.RGBA{}
-		err = binary.Read(r, binary.LittleEndian, &value)
+	// every color is a dword stored blue, green, red then alpha
+	for i := 0; i < int(vertexColorCount); i++ {
+		var bgra [4]uint8
+		err = binary.Read(r, binary.LittleEndian, &bgra)
 		if err != nil {
-			return fmt.Errorf("read r: %w", err)
+			return fmt.Errorf("read color %d: %w", i, err)
 		}
-		err = binary.Read(r, binary.LittleEndian, &value)
-		if err != nil {
-			return fmt.Errorf("read g: %w", err)
-		}
-		err = binary.Read(r, binary.LittleEndian, &value)
-		if err != nil {
-			return fmt.Errorf("read b: %w", err)
-		}
-		err = binary.Read(r, binary.LittleEndian, &value)
-		if err != nil {
-			return fmt.Errorf("read a: %w", err)
-		}
-		v.Colors = append(v.Colors, rgba)
+		v.Colors = append(v.Colors, color.RGBA{R: bgra[2], G: bgra[1], B: bgra[0], A: bgra[3]})
 	}
 
 	return nil
@@ -97,3 +87,35 @@ func parseVertexColor(r io.ReadSeeker, v *VertexColor) error {
 func (v *VertexColor) FragmentType() string {
 	return "Vertex Color"
 }
+
+func (v *VertexColor) FragmentCode() int32 {
+	return 0x32
+}
+
+func (v *VertexColor) Name() string {
+	return v.name
+}
+
+func (v *VertexColor) SetName(name string) {
+	v.name = name
+}
+
+func (v *VertexColor) References() []uint32 {
+	return nil
+}
+
+func (v *VertexColor) Write(w io.Writer) error {
+	// the unknowns are not kept, the ones after the count are written as the values usually seen
+	err := binary.Write(w, binary.LittleEndian, []uint32{0, uint32(len(v.Colors)), 1, 200, 0})
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	for i, c := range v.Colors {
+		err = binary.Write(w, binary.LittleEndian, []uint8{c.B, c.G, c.R, c.A})
+		if err != nil {
+			return fmt.Errorf("write color %d: %w", i, err)
+		}
+	}
+	return nil
+}
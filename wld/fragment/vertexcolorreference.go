@@ -11,6 +11,7 @@ type VertexColorReference struct {
 	VertexColor *VertexColor
 	Reference   uint32
 	hashIndex   uint32
+	name        string
 }
 
 func LoadVertexColorReference(r io.ReadSeeker) (*VertexColorReference, error) {
@@ -42,3 +43,27 @@ func parseVertexColorReference(r io.ReadSeeker, v *VertexColorReference) error {
 func (v *VertexColorReference) FragmentType() string {
 	return "Vertex Color Reference"
 }
+
+func (v *VertexColorReference) FragmentCode() int32 {
+	return 0x33
+}
+
+func (v *VertexColorReference) Name() string {
+	return v.name
+}
+
+func (v *VertexColorReference) SetName(name string) {
+	v.name = name
+}
+
+func (v *VertexColorReference) References() []uint32 {
+	return references(v.Reference)
+}
+
+func (v *VertexColorReference) Write(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, v.Reference)
+	if err != nil {
+		return fmt.Errorf("write reference: %w", err)
+	}
+	return nil
+}
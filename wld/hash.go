@@ -0,0 +1,102 @@
+package wld
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// parseStringHash maps the offset of every null terminated string in a decoded hash to the string
+func parseStringHash(data []byte) map[int]string {
+	hash := make(map[int]string)
+	offset := 0
+	for offset < len(data) {
+		end := offset
+		for end < len(data) && data[end] != 0 {
+			end++
+		}
+		hash[offset] = string(data[offset:end])
+		offset = end + 1
+	}
+	return hash
+}
+
+// namer is a fragment with a name from the string hash
+type namer interface {
+	SetName(name string)
+}
+
+// Name returns the string a name reference points to. References are negative offsets into the string hash,
+// anything else is not a name
+func (wld *Wld) Name(ref int32) string {
+	if ref >= 0 {
+		return ""
+	}
+	return wld.Hash[int(-ref)]
+}
+
+// FragmentByName returns the first fragment named name, ignoring case, such as ZONE_DMSPRITEDEF
+func (wld *Wld) FragmentByName(name string) fragment.Fragment {
+	for _, frag := range wld.Fragments {
+		if strings.EqualFold(frag.Name(), name) {
+			return frag
+		}
+	}
+	return nil
+}
+
+// stringHash gathers names into a string hash as a wld is encoded
+type stringHash struct {
+	data []byte
+	refs map[string]int32
+}
+
+// newStringHashOf starts a hash of size bytes with the names of a decoded hash at their offsets, so they keep their
+// name references and the hash is written as decoded
+func newStringHashOf(names map[int]string, size int) *stringHash {
+	h := &stringHash{data: make([]byte, size), refs: make(map[string]int32)}
+	offsets := []int{}
+	for offset := range names {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	for _, offset := range offsets {
+		name := names[offset]
+		end := offset + len(name)
+		// a decoded name may run to the end of the hash without a null
+		if end != size {
+			end++
+		}
+		for len(h.data) < end {
+			h.data = append(h.data, 0)
+		}
+		copy(h.data[offset:], name)
+		// 0 is no name, so a name at the very start can not be referenced
+		_, ok := h.refs[name]
+		if name != "" && offset > 0 && !ok {
+			h.refs[name] = -int32(offset)
+		}
+	}
+	return h
+}
+
+// ref returns the name reference of name, adding it to the hash the first time it is seen. An empty name is 0
+func (h *stringHash) ref(name string) int32 {
+	if name == "" {
+		return 0
+	}
+	ref, ok := h.refs[name]
+	if ok {
+		return ref
+	}
+	// an empty string at offset 0 keeps names from being referenced by 0, and the last name is null terminated
+	if len(h.data) == 0 || h.data[len(h.data)-1] != 0 {
+		h.data = append(h.data, 0)
+	}
+	ref = -int32(len(h.data))
+	h.refs[name] = ref
+	h.data = append(h.data, name...)
+	h.data = append(h.data, 0)
+	return ref
+}
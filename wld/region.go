@@ -0,0 +1,96 @@
+package wld
+
+import (
+	"fmt"
+
+	"github.com/g3n/engine/math32"
+	"github.com/xackery/eqzxc/wld/fragment"
+)
+
+// Regions returns every bsp region in file order, which is the order region ids count in.
+// They are gathered once, and again only when the number of fragments changes
+func (wld *Wld) Regions() []*fragment.BspRegion {
+	if wld.regions != nil && wld.regionsFragmentCount == len(wld.Fragments) {
+		return wld.regions
+	}
+	wld.regions = []*fragment.BspRegion{}
+	for _, frag := range wld.Fragments {
+		region, ok := frag.(*fragment.BspRegion)
+		if ok {
+			wld.regions = append(wld.regions, region)
+		}
+	}
+	wld.regionsFragmentCount = len(wld.Fragments)
+	return wld.regions
+}
+
+// BspTree returns the world tree of a zone, nil if the wld has none
+func (wld *Wld) BspTree() *fragment.BspTree {
+	for _, frag := range wld.Fragments {
+		tree, ok := frag.(*fragment.BspTree)
+		if ok {
+			return tree
+		}
+	}
+	return nil
+}
+
+// RegionAt returns the index of the region containing point, or -1 if it is outside the world tree
+func (wld *Wld) RegionAt(point math32.Vector3) int {
+	tree := wld.BspTree()
+	if tree == nil {
+		return -1
+	}
+	id := tree.RegionAt(point)
+	if id == 0 || int(id) > len(wld.Regions()) {
+		return -1
+	}
+	return int(id) - 1
+}
+
+// VisibleRegions returns the indices of the regions that can be seen from region.
+// Regions with their visibility stored as words are not decoded and return an error
+func (wld *Wld) VisibleRegions(region int) ([]int, error) {
+	regions := wld.Regions()
+	if region < 0 || region >= len(regions) {
+		return nil, fmt.Errorf("region %d of %d not found", region, len(regions))
+	}
+	if regions[region].Flags&0x20 != 0 {
+		return nil, fmt.Errorf("region %d visibility is stored as words, which is not supported", region)
+	}
+	return regions[region].VisibleRegions, nil
+}
+
+// RegionFlags returns every region flag, the fragments tagging regions as water, lava, zone lines and such
+func (wld *Wld) RegionFlags() []*fragment.RegionFlag {
+	flags := []*fragment.RegionFlag{}
+	for _, frag := range wld.Fragments {
+		flag, ok := frag.(*fragment.RegionFlag)
+		if ok {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// RegionTypes returns the types of region, empty for normal regions
+func (wld *Wld) RegionTypes(region int) []fragment.RegionType {
+	types := []fragment.RegionType{}
+	seen := make(map[fragment.RegionType]bool)
+	for _, flag := range wld.RegionFlags() {
+		for _, index := range flag.RegionIndices {
+			if index != region {
+				continue
+			}
+			for _, t := range flag.Types() {
+				if t == fragment.RegionTypeNormal || seen[t] {
+					continue
+				}
+				seen[t] = true
+				types = append(types, t)
+			}
+			break
+		}
+	}
+	return types
+}
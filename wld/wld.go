@@ -9,5 +9,60 @@ type Wld struct {
 	FragmentCount  uint32
 	BspRegionCount uint32
 	Hash           map[int]string
-	Fragments      []fragment.Fragment
+	// hashSize is the size of the decoded string hash, Hash is encoded at least this long
+	hashSize int
+	// Fragments are in file order, fragments of a type not understood are kept as an UnknownFragment
+	Fragments []fragment.Fragment
+	// regions caches Regions, gathered when there were regionsFragmentCount fragments
+	regions              []*fragment.BspRegion
+	regionsFragmentCount int
+}
+
+// Fragment returns the fragment a reference points to. References count from 1, 0 is no fragment
+func (wld *Wld) Fragment(ref uint32) fragment.Fragment {
+	if ref == 0 || int(ref) > len(wld.Fragments) {
+		return nil
+	}
+	return wld.Fragments[ref-1]
+}
+
+// Add appends frag to the wld, returning the reference other fragments point to it with
+func (wld *Wld) Add(frag fragment.Fragment) uint32 {
+	wld.Fragments = append(wld.Fragments, frag)
+	wld.FragmentCount = uint32(len(wld.Fragments))
+	return wld.FragmentCount
+}
+
+// BitmapFiles returns the texture files of a material, in frame order if animated
+func (wld *Wld) BitmapFiles(m *fragment.Material) []string {
+	files := []string{}
+	infoRef, ok := wld.Fragment(m.BitmapInfoReference).(*fragment.BitmapInfoReference)
+	if !ok {
+		return files
+	}
+	info, ok := wld.Fragment(infoRef.Reference).(*fragment.BitmapInfo)
+	if !ok {
+		return files
+	}
+	for _, ref := range info.BitmapNameReferences {
+		bitmapName, ok := wld.Fragment(ref).(*fragment.BitmapName)
+		if !ok {
+			continue
+		}
+		files = append(files, bitmapName.Files...)
+	}
+	return files
+}
+
+// BoneTrack returns the animation track of a skeleton bone, following its track reference
+func (wld *Wld) BoneTrack(bone *fragment.SkeletonBone) *fragment.Track {
+	trackRef, ok := wld.Fragment(bone.TrackReference).(*fragment.TrackReference)
+	if !ok {
+		return nil
+	}
+	track, ok := wld.Fragment(trackRef.Reference).(*fragment.Track)
+	if !ok {
+		return nil
+	}
+	return track
 }